@@ -2,13 +2,139 @@ package config
 
 import (
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config holds the application settings read from the environment
+type Config struct {
+	// StorageBackend selects the persistence layer: "sqlite" or "memory"
+	StorageBackend string
+	// SQLitePath is the database file used by the sqlite backend
+	SQLitePath string
+	// JWTKeysDir holds the PEM keys that sign access tokens
+	JWTKeysDir string
+	// JWTKeyAlgorithm is the algorithm of generated keys: "EdDSA" or "RS256"
+	JWTKeyAlgorithm string
+	// JWTKeyRotation is how long a key signs tokens before a new one is generated
+	JWTKeyRotation time.Duration
+	// JWTKeyGrace is how long a rotated-out key keeps verifying tokens
+	JWTKeyGrace time.Duration
+
+	// PhoneRegion is the region assumed for phone numbers without a country code
+	PhoneRegion string
+
+	// SMSProvider selects how text messages are sent: "twilio", "log", "file",
+	// "memory" or "webhook"
+	SMSProvider string
+	// SMSFilePath is the JSON lines file written by the file provider
+	SMSFilePath string
+	// SMSWebhookURL receives messages as JSON from the webhook provider
+	SMSWebhookURL string
+	// SMSWebhookToken is sent as a bearer token to the webhook, if set
+	SMSWebhookToken string
+
+	// Twilio credentials for the twilio provider
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFromNumber string
+	// TwilioAPIURL overrides the Twilio API host, e.g. with a local fake server
+	TwilioAPIURL string
+
+	// EmailProvider selects how emails are sent: "smtp", "log" or "memory"
+	EmailProvider string
+	// EmailFrom is the sender address of every email
+	EmailFrom string
+	// EmailLoginURL is the page that signs a user in from an emailed login
+	// link. The link adds the address and code as the email and code query
+	// parameters. Login emails only contain the code if it is unset.
+	EmailLoginURL string
+
+	// SMTP server of the smtp provider. An empty username disables authentication.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
 // LoadEnv loads environment variables from .env file
 func LoadEnv() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("Error loading .env file:", err)
 	}
 }
+
+// Load reads the application settings from the environment
+func Load() Config {
+	return Config{
+		StorageBackend: getEnv("STORAGE_BACKEND", "sqlite"),
+		SQLitePath:     getEnv("SQLITE_PATH", "rondo.db"),
+
+		JWTKeysDir:      getEnv("JWT_KEYS_DIR", "keys"),
+		JWTKeyAlgorithm: getEnv("JWT_KEY_ALGORITHM", "EdDSA"),
+		JWTKeyRotation:  getDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTKeyGrace:     getDuration("JWT_KEY_GRACE", time.Hour),
+
+		PhoneRegion: getEnv("PHONE_DEFAULT_REGION", "US"),
+
+		SMSProvider:     getEnv("SMS_PROVIDER", "twilio"),
+		SMSFilePath:     getEnv("SMS_FILE_PATH", "sms.jsonl"),
+		SMSWebhookURL:   os.Getenv("SMS_WEBHOOK_URL"),
+		SMSWebhookToken: os.Getenv("SMS_WEBHOOK_TOKEN"),
+
+		TwilioAccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+		TwilioFromNumber: os.Getenv("TWILIO_FROM_NUMBER"),
+		TwilioAPIURL:     os.Getenv("TWILIO_API_URL"),
+
+		EmailProvider: getEnv("EMAIL_PROVIDER", "log"),
+		EmailFrom:     os.Getenv("EMAIL_FROM"),
+		EmailLoginURL: os.Getenv("EMAIL_LOGIN_URL"),
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getInt("SMTP_PORT", 587),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+// getEnv returns the value of an environment variable or a fallback if it is unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getInt returns an environment variable parsed as an integer, or a fallback
+// if it is unset or invalid
+func getInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid number %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return number
+}
+
+// getDuration returns an environment variable parsed as a duration such as
+// "720h", or a fallback if it is unset or invalid
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return duration
+}
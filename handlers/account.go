@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+)
+
+// accountDeletedReason is the cancellation reason of games whose creator
+// deleted their account
+const accountDeletedReason = "The organizer deleted their account"
+
+// ExportAccount returns everything stored about the signed-in user as a JSON
+// file download
+func ExportAccount(c *gin.Context) {
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+
+	export := models.AccountExport{
+		Profile:        newAdminUserResponse(user),
+		Sessions:       []models.SessionResponse{},
+		CreatedGames:   []models.GameResponse{},
+		CreatedSeries:  []models.GameSeries{},
+		Participations: []models.ParticipationExport{},
+		GameChanges:    []models.GameChange{},
+		RatingChanges:  []models.RatingChange{},
+		Following:      []models.Follow{},
+		Followers:      []models.Follow{},
+		Clubs:          []models.ClubMember{},
+		JoinRequests:   []models.ClubJoinRequest{},
+		ExportedAt:     time.Now(),
+	}
+
+	sessions, err := Repos.Sessions.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sessions"})
+		return
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, models.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == c.GetString("sessionID"),
+		})
+	}
+
+	games, err := Repos.Games.Search(models.GameFilter{CreatorID: user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load games"})
+		return
+	}
+	for _, game := range games {
+		export.CreatedGames = append(export.CreatedGames, newGameResponse(game))
+	}
+
+	series, err := Repos.Series.ListByCreator(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series"})
+		return
+	}
+	export.CreatedSeries = append(export.CreatedSeries, series...)
+
+	participants, err := Repos.Participants.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participations"})
+		return
+	}
+	for _, participant := range participants {
+		participation := models.ParticipationExport{Participant: participant}
+		game, err := Repos.Games.Get(participant.GameID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load games"})
+			return
+		}
+		if err == nil {
+			participation.EventName = game.EventName
+			participation.Location = game.Location
+			participation.StartTime = game.StartTime
+		}
+		export.Participations = append(export.Participations, participation)
+	}
+
+	changes, err := Repos.History.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game changes"})
+		return
+	}
+	export.GameChanges = append(export.GameChanges, changes...)
+
+	ratingChanges, err := Repos.Ratings.History(user.ID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating history"})
+		return
+	}
+	export.RatingChanges = append(export.RatingChanges, ratingChanges...)
+
+	following, err := Repos.Follows.ListFollowing(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load follows"})
+		return
+	}
+	export.Following = append(export.Following, following...)
+	followers, err := Repos.Follows.ListFollowers(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load followers"})
+		return
+	}
+	export.Followers = append(export.Followers, followers...)
+
+	memberships, err := Repos.Clubs.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clubs"})
+		return
+	}
+	export.Clubs = append(export.Clubs, memberships...)
+	joinRequests, err := Repos.Clubs.ListJoinRequestsByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load join requests"})
+		return
+	}
+	export.JoinRequests = append(export.JoinRequests, joinRequests...)
+
+	token, err := Repos.Calendars.GetByUser(user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar feed"})
+		return
+	}
+	if err == nil {
+		createdAt := token.CreatedAt
+		export.CalendarFeedCreatedAt = &createdAt
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="rondo-export-%s.json"`, user.ID))
+	c.IndentedJSON(http.StatusOK, export)
+}
+
+// DeleteAccount deletes the signed-in user's account. Their upcoming games
+// and series are cancelled and they leave the upcoming games they joined.
+// Accounts that never took part in a game are removed outright; the others
+// are anonymized so past games keep their creator and roster. Either way
+// every session, the calendar feed, pending codes, follows and club
+// memberships are removed. Owners of clubs with other members have to hand
+// them over first.
+func DeleteAccount(c *gin.Context) {
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+
+	// Clubs the user owns alone are deleted with their membership
+	memberships, err := Repos.Clubs.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clubs"})
+		return
+	}
+	for _, membership := range memberships {
+		if membership.Role != models.ClubRoleOwner {
+			continue
+		}
+		members, err := Repos.Clubs.ListMembers(membership.ClubID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load club members"})
+			return
+		}
+		if len(members) > 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Make another member the owner of each club you own before deleting your account"})
+			return
+		}
+	}
+
+	now := time.Now()
+
+	// Series are cancelled first so no new games are generated for them
+	createdSeries, err := Repos.Series.ListByCreator(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series"})
+		return
+	}
+	for _, series := range createdSeries {
+		if series.Status != models.SeriesActive {
+			continue
+		}
+		series.Status = models.SeriesCancelled
+		series.EndsBefore = now
+		series.UpdatedAt = now
+		if err := Repos.Series.Update(series); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel series " + series.ID})
+			return
+		}
+	}
+
+	created, err := Repos.Games.Search(models.GameFilter{CreatorID: user.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load games"})
+		return
+	}
+	for _, game := range created {
+		if !game.IsOpen() || !game.StartTime.After(now) {
+			continue
+		}
+		game.Status = models.GameCancelled
+		game.CancelReason = accountDeletedReason
+		game.Sequence++
+		game.UpdatedAt = now
+		if err := Repos.Games.Update(game); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel game " + game.ID})
+			return
+		}
+
+		notifyRoster(game.ID, fmt.Sprintf("%s on %s has been cancelled: %s",
+			game.EventName, game.StartTime.Format("Mon 2 Jan 15:04"), game.CancelReason))
+	}
+
+	participants, err := Repos.Participants.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participations"})
+		return
+	}
+	for _, participant := range participants {
+		if participant.Status != models.ParticipantJoined && participant.Status != models.ParticipantWaitlisted {
+			continue
+		}
+		game, err := Repos.Games.Get(participant.GameID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game " + participant.GameID})
+			return
+		}
+		// Rosters of games that have started or ended are kept as they were
+		if !game.IsOpen() || !game.StartTime.After(now) {
+			continue
+		}
+
+		// Waitlisted users can only move onto the roster, so trying the waitlist
+		// first catches a promotion made since the record was read
+		wasJoined := false
+		err = Repos.Participants.Transition(game.ID, user.ID, models.ParticipantWaitlisted, models.ParticipantLeft, now)
+		if errors.Is(err, repository.ErrNotFound) {
+			wasJoined = true
+			err = Repos.Participants.Transition(game.ID, user.ID, models.ParticipantJoined, models.ParticipantLeft, now)
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			// Removed by an organizer in the meantime
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave game " + game.ID})
+			return
+		}
+		if wasJoined {
+			promoteFromWaitlist(game)
+		}
+	}
+
+	changes, err := Repos.History.ListByUser(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game changes"})
+		return
+	}
+
+	// Pending sign-in, phone change and email codes
+	keys := []string{user.Phone, phoneChangeKey(user.ID), emailVerifyKey(user.ID)}
+	if user.Email != "" {
+		keys = append(keys, emailLoginKey(user.Email))
+	}
+	for _, key := range keys {
+		if err := Repos.OTPs.Delete(key); err != nil {
+			log.Printf("Failed to delete OTP of user %s: %v", user.ID, err)
+		}
+	}
+
+	if err := Repos.Follows.DeleteByUser(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove follows"})
+		return
+	}
+	if err := Repos.Clubs.DeleteByUser(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave clubs"})
+		return
+	}
+
+	if len(created) == 0 && len(createdSeries) == 0 && len(participants) == 0 && len(changes) == 0 {
+		if err := Repos.Users.Delete(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+			return
+		}
+		c.JSON(http.StatusOK, models.AccountDeletionResponse{Message: "Account deleted"})
+		return
+	}
+
+	if err := Repos.Sessions.RevokeAll(user.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := Repos.Calendars.Delete(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke calendar feed"})
+		return
+	}
+
+	// The phone number is replaced with a placeholder that can never be
+	// signed in with, freeing the real number for a new account
+	user.FirstName = "Deleted"
+	user.LastName = "user"
+	user.DOB = time.Time{}
+	user.Phone = "deleted:" + user.ID
+	user.Email = ""
+	user.DisplayName = ""
+	user.Visibility = models.ProfileVisibility{}
+	user.Position = ""
+	user.Role = models.RolePlayer
+	user.DeletedAt = now
+	user.UpdatedAt = now
+	if err := Repos.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountDeletionResponse{Message: "Account deleted", Anonymized: true})
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// Page sizes for the admin user listing
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// AdminListUsers lists and searches users. Query parameters:
+//
+//	q          case-insensitive substring of the name or phone number
+//	role       player, organizer or admin
+//	suspended  "true" or "false"
+//	limit      page size, 1 to 200 (default 50)
+//	cursor     next_cursor of the previous page
+func AdminListUsers(c *gin.Context) {
+	filter := models.UserFilter{
+		Query: strings.TrimSpace(c.Query("q")),
+		Role:  c.Query("role"),
+		Limit: defaultUserPageSize,
+	}
+
+	if filter.Role != "" && !models.ValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be player, organizer or admin"})
+		return
+	}
+	if value := c.Query("suspended"); value != "" {
+		suspended, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "suspended must be true or false"})
+			return
+		}
+		filter.Suspended = &suspended
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxUserPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxUserPageSize)})
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeUserCursor(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		filter.After = &cursor
+	}
+
+	// Fetch one extra user to find out whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	users, err := Repos.Users.Search(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	response := models.UserListResponse{Users: []models.AdminUserResponse{}}
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		response.NextCursor = encodeUserCursor(models.UserCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, newAdminUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminGetUser returns any user, including their suspension
+func AdminGetUser(c *gin.Context) {
+	user, ok := loadUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserResponse(user))
+}
+
+// AdminGetUserByPhone looks a user up by phone number, in any notation
+func AdminGetUserByPhone(c *gin.Context) {
+	phone, err := utils.NormalizePhone(c.Param("phone"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	user, err := Repos.Users.GetByPhone(phone)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserResponse(user))
+}
+
+// AdminUpdateRole changes a user's role. Admins cannot change their own role,
+// so there is always at least one admin left.
+func AdminUpdateRole(c *gin.Context) {
+	var req models.RoleUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be player, organizer or admin"})
+		return
+	}
+
+	if c.Param("id") == c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot change their own role"})
+		return
+	}
+
+	user, ok := loadUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	user.Role = req.Role
+	user.UpdatedAt = time.Now()
+	if err := Repos.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserResponse(user))
+}
+
+// AdminSuspendUser suspends an account and signs it out everywhere
+func AdminSuspendUser(c *gin.Context) {
+	var req models.SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A suspension reason is required"})
+		return
+	}
+
+	if c.Param("id") == c.GetString("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot suspend themselves"})
+		return
+	}
+
+	user, ok := loadUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if !user.IsSuspended() {
+		user.SuspendedAt = now
+	}
+	user.SuspendedReason = req.Reason
+	user.UpdatedAt = now
+	if err := Repos.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if err := Repos.Sessions.RevokeAll(user.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserResponse(user))
+}
+
+// AdminUnsuspendUser lifts a suspension. The user signs in again with an OTP.
+func AdminUnsuspendUser(c *gin.Context) {
+	user, ok := loadUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	user.SuspendedAt = time.Time{}
+	user.SuspendedReason = ""
+	user.UpdatedAt = time.Now()
+	if err := Repos.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserResponse(user))
+}
+
+// AdminCancelGame cancels any game regardless of who created it
+func AdminCancelGame(c *gin.Context) {
+	var req models.CancelGameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A cancellation reason is required"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	cancelGame(c, game, req.Reason)
+}
+
+// AdminStats returns counts of users, games and sessions
+func AdminStats(c *gin.Context) {
+	stats, err := Repos.Stats.Get(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// loadUser fetches a user by ID, writing a not found or error response if it
+// cannot be loaded
+func loadUser(c *gin.Context, userID string) (models.User, bool) {
+	user, err := Repos.Users.GetByID(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return models.User{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// newAdminUserResponse builds the admin representation of a user
+func newAdminUserResponse(user models.User) models.AdminUserResponse {
+	response := models.AdminUserResponse{
+		UserResponse:    newUserResponse(user),
+		SuspendedReason: user.SuspendedReason,
+		UpdatedAt:       user.UpdatedAt,
+	}
+	if user.IsSuspended() {
+		suspendedAt := user.SuspendedAt
+		response.SuspendedAt = &suspendedAt
+	}
+	if user.IsDeleted() {
+		deletedAt := user.DeletedAt
+		response.DeletedAt = &deletedAt
+	}
+	return response
+}
+
+// encodeUserCursor serializes a cursor into an opaque URL-safe token
+func encodeUserCursor(cursor models.UserCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor parses a token produced by encodeUserCursor
+func decodeUserCursor(token string) (models.UserCursor, error) {
+	var cursor models.UserCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
@@ -1,24 +1,107 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	
 	"rondo/models"
+	"rondo/repository"
 	"rondo/utils"
 )
 
-// TwilioClient is the global Twilio client
-var TwilioClient *utils.TwilioClient
+// SMS sends text messages to users
+var SMS utils.SMSSender
+
+// Email sends emails to users
+var Email utils.EmailSender
+
+// EmailLoginURL is the page that completes a login from an emailed link.
+// Login emails only contain the code if it is empty.
+var EmailLoginURL string
+
+// Repos is the persistence layer used by the handlers
+var Repos *repository.Repositories
 
 // InitHandlers initializes the handlers
-func InitHandlers(twilioClient *utils.TwilioClient) {
-	TwilioClient = twilioClient
+func InitHandlers(sms utils.SMSSender, email utils.EmailSender, repos *repository.Repositories) {
+	SMS = sms
+	Email = email
+	Repos = repos
+}
+
+// OTP policy
+const (
+	otpLifetime       = 5 * time.Minute
+	otpResendInterval = time.Minute
+	otpMaxAttempts    = 5
+	// The first lockout lasts otpBaseLockout and each further one twice as
+	// long, up to otpMaxLockout
+	otpBaseLockout = 15 * time.Minute
+	otpMaxLockout  = 24 * time.Hour
+)
+
+// otpError is a rejected OTP request or verification. It is reported with a
+// machine-readable code so clients can tell the cases apart.
+type otpError struct {
+	status            int
+	code              string
+	message           string
+	retryAfter        time.Duration
+	attemptsRemaining int
+}
+
+func (e *otpError) Error() string {
+	return e.message
+}
+
+// respondWithOTPError writes an otpError, or a generic failure for any other error
+func respondWithOTPError(c *gin.Context, err error, fallback string) {
+	var otpErr *otpError
+	if !errors.As(err, &otpErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+		return
+	}
+
+	response := gin.H{"error": otpErr.message, "code": otpErr.code}
+	if otpErr.retryAfter > 0 {
+		seconds := int(math.Ceil(otpErr.retryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		response["retry_after"] = seconds
+	}
+	if otpErr.code == models.OTPErrorInvalid {
+		response["attempts_remaining"] = otpErr.attemptsRemaining
+	}
+	c.JSON(otpErr.status, response)
 }
 
-// RequestOTP handles OTP request
+// otpLockedError reports a phone number that is locked out until a given time
+func otpLockedError(until, now time.Time) *otpError {
+	return &otpError{
+		status:     http.StatusTooManyRequests,
+		code:       models.OTPErrorLocked,
+		message:    "Too many failed attempts, try again later",
+		retryAfter: until.Sub(now),
+	}
+}
+
+// otpLockout returns how long the nth lockout of a phone number lasts
+func otpLockout(n int) time.Duration {
+	lockout := otpBaseLockout
+	for i := 1; i < n && lockout < otpMaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, otpMaxLockout)
+}
+
+// RequestOTP handles OTP request. A new code replaces any earlier one, but
+// codes cannot be resent more often than otpResendInterval or while the
+// phone number is locked out.
 func RequestOTP(c *gin.Context) {
 	var req models.OTPRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -26,19 +109,23 @@ func RequestOTP(c *gin.Context) {
 		return
 	}
 
-	otp := utils.GenerateOTP()
-	utils.StoreOTP(req.PhoneNumber, otp)
+	// Every notation of a number maps to the same E.164 form
+	phone, err := utils.NormalizePhone(req.PhoneNumber)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+	req.PhoneNumber = phone
 
-	// Send OTP via Twilio
-	if err := TwilioClient.SendOTP(req.PhoneNumber, otp); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
+	if !sendOTP(c, req.PhoneNumber, req.PhoneNumber, "") {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "OTP sent successfully"})
 }
 
-// VerifyOTP handles OTP verification
+// VerifyOTP handles OTP verification. Each code allows otpMaxAttempts
+// guesses, after which it is invalidated and the phone number is locked out.
 func VerifyOTP(c *gin.Context) {
 	var req models.OTPVerify
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -46,57 +133,190 @@ func VerifyOTP(c *gin.Context) {
 		return
 	}
 
-	otpData, exists := utils.GetOTP(req.PhoneNumber)
-	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No OTP request found for this phone number"})
+	// Every notation of a number maps to the same E.164 form
+	phone, err := utils.NormalizePhone(req.PhoneNumber)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
 		return
 	}
+	req.PhoneNumber = phone
 
-	// Check if OTP has expired (5 minutes)
-	if time.Since(otpData.CreatedAt) > 5*time.Minute {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP has expired"})
+	if _, ok := verifyOTP(c, req.PhoneNumber, req.OTP); !ok {
 		return
 	}
 
-	// Verify OTP
-	if otpData.OTP != req.OTP {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OTP"})
+	// Check if user exists
+	user, err := Repos.Users.GetByPhone(req.PhoneNumber)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
 		return
 	}
-
-	// Check if user exists
-	user, exists := utils.GetUserByPhone(req.PhoneNumber)
-	var token string
-	var err error
+	response := gin.H{"message": "Phone number verified successfully"}
 	
-	if exists {
-		// Generate JWT token for existing user
-		token, err = utils.GenerateJWT(user)
+	if err == nil {
+		if user.IsSuspended() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended", "reason": user.SuspendedReason})
+			return
+		}
+		
+		// Start a session for the existing user
+		token, refreshToken, err := issueSession(c, user)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 			return
 		}
+		response = tokenResponse(response, token, refreshToken)
 	} else {
-		// For phone numbers without registered users, generate a temporary token
-		// This token will be used for registration
-		tempUser := models.User{
-			ID:    "temp_" + req.PhoneNumber,
-			Phone: req.PhoneNumber,
-		}
-		token, err = utils.GenerateJWT(tempUser)
+		// Phone numbers without a registered user get a short-lived token
+		// that can only be used to register
+		token, err := utils.GenerateRegistrationJWT(req.PhoneNumber)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
 		}
+		response["token"] = token
+		response["expires_in"] = int(utils.RegistrationTokenTTL.Seconds())
 	}
 
-	// Remove OTP from store after successful verification
-	utils.DeleteOTP(req.PhoneNumber)
-	
-	response := gin.H{
-		"message": "Phone number verified successfully",
-		"token":   token,
+	// Clear the attempt and lockout history after successful verification
+	if err := Repos.OTPs.Delete(req.PhoneNumber); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear OTP"})
+		return
 	}
 	
 	c.JSON(http.StatusOK, response)
 }
+
+// sendOTP generates a code, stores it under key and texts it to phone,
+// writing an error response if it cannot. target is stored with the code for
+// the flow that verifies it.
+func sendOTP(c *gin.Context, key, phone, target string) bool {
+	otp, ok := storeOTP(c, key, target)
+	if !ok {
+		return false
+	}
+
+	// Send the OTP by text message
+	if err := SMS.SendOTP(phone, otp); err != nil {
+		log.Printf("Failed to send OTP to %s: %v", phone, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP"})
+		return false
+	}
+	return true
+}
+
+// storeOTP generates a code and stores it under key, returning the code for
+// the caller to deliver. It writes an error response if it cannot. A new
+// code replaces any earlier one, but codes cannot be resent more often than
+// otpResendInterval or while the key is locked out.
+func storeOTP(c *gin.Context, key, target string) (string, bool) {
+	otp, err := utils.GenerateOTP()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
+		return "", false
+	}
+	salt, err := utils.NewOTPSalt()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate OTP"})
+		return "", false
+	}
+
+	now := time.Now()
+	err = Repos.OTPs.Update(key, func(data *models.OTPData, exists bool) error {
+		if data.LockedUntil.After(now) {
+			return otpLockedError(data.LockedUntil, now)
+		}
+		if exists && now.Sub(data.CreatedAt) < otpResendInterval {
+			return &otpError{
+				status:     http.StatusTooManyRequests,
+				code:       models.OTPErrorResendCooldown,
+				message:    "An OTP was sent recently, wait before requesting another",
+				retryAfter: data.CreatedAt.Add(otpResendInterval).Sub(now),
+			}
+		}
+
+		// Lockouts are forgotten once a phone number has behaved for a while
+		if now.Sub(data.LockedUntil) > otpMaxLockout {
+			data.Lockouts = 0
+		}
+		data.CodeHash = utils.HashOTP(otp, salt)
+		data.Salt = salt
+		data.CreatedAt = now
+		data.Attempts = 0
+		data.Target = target
+		return nil
+	})
+	if err != nil {
+		respondWithOTPError(c, err, "Failed to store OTP")
+		return "", false
+	}
+	return otp, true
+}
+
+// verifyOTP checks a code against the one stored under key and consumes it,
+// returning the OTP state it was stored with. It writes an error response if
+// the code is wrong. Each code allows otpMaxAttempts guesses, after which it
+// is invalidated and the key is locked out.
+func verifyOTP(c *gin.Context, key, code string) (models.OTPData, bool) {
+	// A failed guess is stored before it is reported, so the failure is kept
+	// apart from the error that would roll the update back
+	var failure *otpError
+	now := time.Now()
+	var verified models.OTPData
+	err := Repos.OTPs.Update(key, func(data *models.OTPData, exists bool) error {
+		if data.LockedUntil.After(now) {
+			return otpLockedError(data.LockedUntil, now)
+		}
+		if !exists || data.CodeHash == "" {
+			return &otpError{
+				status:  http.StatusBadRequest,
+				code:    models.OTPErrorNotFound,
+				message: "No OTP request found",
+			}
+		}
+		if now.Sub(data.CreatedAt) > otpLifetime {
+			return &otpError{
+				status:  http.StatusBadRequest,
+				code:    models.OTPErrorExpired,
+				message: "OTP has expired",
+			}
+		}
+
+		if !utils.CheckOTP(code, data.Salt, data.CodeHash) {
+			data.Attempts++
+			if data.Attempts < otpMaxAttempts {
+				failure = &otpError{
+					status:            http.StatusBadRequest,
+					code:              models.OTPErrorInvalid,
+					message:           "Invalid OTP",
+					attemptsRemaining: otpMaxAttempts - data.Attempts,
+				}
+				return nil
+			}
+
+			data.CodeHash, data.Salt = "", ""
+			data.Lockouts++
+			data.LockedUntil = now.Add(otpLockout(data.Lockouts))
+			failure = &otpError{
+				status:     http.StatusTooManyRequests,
+				code:       models.OTPErrorAttemptsExceeded,
+				message:    "Too many failed attempts, request a new OTP later",
+				retryAfter: data.LockedUntil.Sub(now),
+			}
+			return nil
+		}
+
+		// Consume the code so it cannot be used twice
+		verified = *data
+		data.CodeHash, data.Salt = "", ""
+		return nil
+	})
+	if err == nil && failure != nil {
+		err = failure
+	}
+	if err != nil {
+		respondWithOTPError(c, err, "Failed to verify OTP")
+		return models.OTPData{}, false
+	}
+	return verified, true
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// calendarFeedHistory is how far back the calendar feed reaches for past games
+const calendarFeedHistory = 90 * 24 * time.Hour
+
+// ExportGameCalendar returns a single game as an iCalendar file
+func ExportGameCalendar(c *gin.Context) {
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="game-%s.ics"`, game.ID))
+	writeCalendar(c, game.EventName, []models.Game{game})
+}
+
+// CreateCalendarFeed issues a secret calendar feed URL for the authenticated
+// user, revoking any URL issued before. The URL is only shown once.
+func CreateCalendarFeed(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	secret, err := utils.GenerateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate calendar token"})
+		return
+	}
+
+	token := models.CalendarToken{
+		UserID:    userID.(string),
+		TokenHash: utils.HashToken(secret),
+		CreatedAt: time.Now(),
+	}
+	if err := Repos.Calendars.Save(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CalendarFeedResponse{
+		FeedURL:   requestBaseURL(c) + "/calendar/feed/" + secret,
+		CreatedAt: token.CreatedAt,
+	})
+}
+
+// RevokeCalendarFeed disables the authenticated user's calendar feed URL
+func RevokeCalendarFeed(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := Repos.Calendars.Delete(userID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked"})
+}
+
+// CalendarFeed serves the games a user created or joined as an iCalendar
+// feed. Upcoming games the user left or was removed from stay in the feed as
+// cancelled, so calendar apps drop them instead of keeping a stale copy.
+// Calendar apps cannot send JWTs, so the secret token in the URL
+// authenticates the request instead.
+func CalendarFeed(c *gin.Context) {
+	token, err := Repos.Calendars.GetByHash(utils.HashToken(c.Param("token")))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar feed"})
+		return
+	}
+
+	now := time.Now()
+	games, err := Repos.Games.Search(models.GameFilter{
+		MemberID:   token.UserID,
+		StartAfter: now.Add(-calendarFeedHistory),
+		SortBy:     models.SortByStartTime,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load games"})
+		return
+	}
+	participants, err := Repos.Participants.ListByUser(token.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participations"})
+		return
+	}
+
+	// Each event's sequence also counts the user's own changes to the
+	// participation, so leaving and rejoining both replace the earlier copy
+	participations := make(map[string]models.Participant, len(participants))
+	for _, participant := range participants {
+		participations[participant.GameID] = participant
+	}
+	inFeed := make(map[string]bool, len(games))
+	for i := range games {
+		inFeed[games[i].ID] = true
+		games[i].Sequence += participations[games[i].ID].Sequence
+	}
+
+	for _, participant := range participants {
+		if inFeed[participant.GameID] ||
+			(participant.Status != models.ParticipantLeft && participant.Status != models.ParticipantRemoved) {
+			continue
+		}
+		game, err := Repos.Games.Get(participant.GameID)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load games"})
+			return
+		}
+		if !game.StartTime.After(now) {
+			continue
+		}
+
+		game.Sequence += participant.Sequence
+		if game.Status != models.GameCancelled {
+			game.Status = models.GameCancelled
+			game.CancelReason = "You left this game"
+			if participant.Status == models.ParticipantRemoved {
+				game.CancelReason = "You were removed from this game"
+			}
+		}
+		games = append(games, game)
+	}
+	sort.SliceStable(games, func(i, j int) bool {
+		return games[i].StartTime.Before(games[j].StartTime)
+	})
+
+	writeCalendar(c, "Rondo games", games)
+}
+
+// writeCalendar writes games as an iCalendar response
+func writeCalendar(c *gin.Context, name string, games []models.Game) {
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(utils.GameCalendar(name, games, time.Now())))
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the
+// server, honouring a reverse proxy's X-Forwarded-Proto header
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// useMemoryRepos points the handlers at a fresh in-memory backend
+func useMemoryRepos(t *testing.T) *repository.Repositories {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	repos := repository.NewMemory()
+	InitHandlers(utils.NewMemorySMS(), utils.NewMemoryEmail(), repos)
+	t.Cleanup(func() { InitHandlers(nil, nil, nil) })
+	return repos
+}
+
+// storeGame saves an upcoming game created by creatorID
+func storeGame(t *testing.T, repos *repository.Repositories, game models.Game, creatorID string) {
+	t.Helper()
+
+	now := time.Now()
+	game.EventName, game.Location, game.PlayerRequirement = "5-a-side", "Court 1", 10
+	game.StartTime, game.EndTime = now.Add(24*time.Hour), now.Add(26*time.Hour)
+	game.CreatorID, game.Status, game.CreatedAt, game.UpdatedAt = creatorID, models.GameScheduled, now, now
+	if err := repos.Games.Create(game); err != nil {
+		t.Fatalf("create game %s: %v", game.ID, err)
+	}
+}
+
+// feedEvent fetches a calendar feed and returns the VEVENT of a game, or an
+// empty string if the feed does not have one
+func feedEvent(t *testing.T, secret, gameID string) string {
+	t.Helper()
+
+	r := gin.New()
+	r.GET("/calendar/feed/:token", CalendarFeed)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/calendar/feed/"+secret, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("feed: status %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, event := range strings.Split(w.Body.String(), "BEGIN:VEVENT") {
+		if strings.Contains(event, "UID:"+gameID+"@rondo") {
+			return event
+		}
+	}
+	return ""
+}
+
+func TestCalendarFeedCancelsGamesTheUserLeft(t *testing.T) {
+	repos := useMemoryRepos(t)
+	storeGame(t, repos, models.Game{ID: "left"}, "owner")
+	storeGame(t, repos, models.Game{ID: "removed"}, "owner")
+	storeGame(t, repos, models.Game{ID: "joined"}, "owner")
+	if err := repos.Calendars.Save(models.CalendarToken{UserID: "ana", TokenHash: utils.HashToken("secret"), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save calendar token: %v", err)
+	}
+	for _, gameID := range []string{"left", "removed", "joined"} {
+		if _, err := repos.Participants.Join(gameID, "ana", time.Now()); err != nil {
+			t.Fatalf("join %s: %v", gameID, err)
+		}
+	}
+	if event := feedEvent(t, "secret", "left"); !strings.Contains(event, "SEQUENCE:0") || !strings.Contains(event, "STATUS:TENTATIVE") {
+		t.Fatalf("joined game event:\n%s", event)
+	}
+
+	if err := repos.Participants.Transition("left", "ana", models.ParticipantJoined, models.ParticipantLeft, time.Now()); err != nil {
+		t.Fatalf("leave: %v", err)
+	}
+	if err := repos.Participants.Transition("removed", "ana", models.ParticipantJoined, models.ParticipantRemoved, time.Now()); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	for _, gameID := range []string{"left", "removed"} {
+		if event := feedEvent(t, "secret", gameID); !strings.Contains(event, "SEQUENCE:1") || !strings.Contains(event, "STATUS:CANCELLED") {
+			t.Errorf("event of game %s after leaving it:\n%s", gameID, event)
+		}
+	}
+	if event := feedEvent(t, "secret", "joined"); !strings.Contains(event, "SEQUENCE:0") || !strings.Contains(event, "STATUS:TENTATIVE") {
+		t.Errorf("event of a game still joined:\n%s", event)
+	}
+
+	// Rejoining publishes the event again with a sequence above the cancellation
+	if _, err := repos.Participants.Join("left", "ana", time.Now()); err != nil {
+		t.Fatalf("rejoin: %v", err)
+	}
+	if event := feedEvent(t, "secret", "left"); !strings.Contains(event, "SEQUENCE:2") || !strings.Contains(event, "STATUS:TENTATIVE") {
+		t.Errorf("event after rejoining:\n%s", event)
+	}
+}
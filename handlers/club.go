@@ -0,0 +1,640 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"rondo/models"
+	"rondo/repository"
+)
+
+// Limits on club text fields, in characters
+const (
+	maxClubNameLength        = 80
+	maxClubDescriptionLength = 1000
+	maxJoinMessageLength     = 500
+)
+
+// CreateClub creates a club owned by the signed-in user, who must be an
+// organizer. Club admins need no role of their own.
+func CreateClub(c *gin.Context) {
+	var req models.ClubCreationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, ok := clubText(c, "name", req.Name, maxClubNameLength, true)
+	if !ok {
+		return
+	}
+	description, ok := clubText(c, "description", req.Description, maxClubDescriptionLength, false)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	club := models.Club{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	owner := models.ClubMember{ClubID: club.ID, UserID: c.GetString("userID"), Role: models.ClubRoleOwner, JoinedAt: now}
+	if err := Repos.Clubs.Create(club, owner); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create club"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ClubResponse{
+		ID:          club.ID,
+		Name:        club.Name,
+		Description: club.Description,
+		MemberCount: 1,
+		Role:        owner.Role,
+		CreatedAt:   club.CreatedAt,
+	})
+}
+
+// GetClub returns a club with the signed-in user's role in it
+func GetClub(c *gin.Context) {
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	response, ok := newClubResponse(c, club)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListMyClubs returns the clubs the signed-in user belongs to, in the order
+// they joined them
+func ListMyClubs(c *gin.Context) {
+	memberships, err := Repos.Clubs.ListByUser(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clubs"})
+		return
+	}
+
+	response := models.ClubListResponse{Clubs: []models.ClubResponse{}}
+	for _, membership := range memberships {
+		club, err := Repos.Clubs.Get(membership.ClubID)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load clubs"})
+			return
+		}
+		clubResponse, ok := newClubResponse(c, club)
+		if !ok {
+			return
+		}
+		response.Clubs = append(response.Clubs, clubResponse)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateClub lets club admins change the club's name and description
+func UpdateClub(c *gin.Context) {
+	var req models.ClubUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if _, ok := requireClubAdmin(c, club.ID, "edit this club"); !ok {
+		return
+	}
+
+	if req.Name != nil {
+		if club.Name, ok = clubText(c, "name", *req.Name, maxClubNameLength, true); !ok {
+			return
+		}
+	}
+	if req.Description != nil {
+		if club.Description, ok = clubText(c, "description", *req.Description, maxClubDescriptionLength, false); !ok {
+			return
+		}
+	}
+
+	club.UpdatedAt = time.Now()
+	if err := Repos.Clubs.Update(club); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update club"})
+		return
+	}
+
+	response, ok := newClubResponse(c, club)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListClubMembers returns a club's members to the other members: the owner
+// first, then the admins, then everyone else in the order they joined
+func ListClubMembers(c *gin.Context) {
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	member, ok := clubMembership(c, club.ID, c.GetString("userID"))
+	if !ok {
+		return
+	}
+	if member.Role == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only club members can see its members"})
+		return
+	}
+
+	members, err := Repos.Clubs.ListMembers(club.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+		return
+	}
+	rank := map[string]int{models.ClubRoleOwner: 0, models.ClubRoleAdmin: 1, models.ClubRoleMember: 2}
+	sort.SliceStable(members, func(i, j int) bool {
+		return rank[members[i].Role] < rank[members[j].Role]
+	})
+
+	response := models.ClubMemberListResponse{ClubID: club.ID, Members: []models.ClubMemberResponse{}}
+	for _, member := range members {
+		response.Members = append(response.Members, newClubMemberResponse(member))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ChangeClubRole lets the club owner make members admins and back. Making a
+// member the owner hands over ownership, leaving the previous owner an admin.
+func ChangeClubRole(c *gin.Context) {
+	var req models.ClubRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role != models.ClubRoleOwner && req.Role != models.ClubRoleAdmin && req.Role != models.ClubRoleMember {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be owner, admin or member"})
+		return
+	}
+
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	owner, ok := clubMembership(c, club.ID, c.GetString("userID"))
+	if !ok {
+		return
+	}
+	if owner.Role != models.ClubRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the club owner can change roles"})
+		return
+	}
+	if c.Param("user_id") == owner.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role; make another member the owner instead"})
+		return
+	}
+
+	member, ok := clubMembership(c, club.ID, c.Param("user_id"))
+	if !ok {
+		return
+	}
+	if member.Role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	member.Role = req.Role
+	updates := []models.ClubMember{member}
+	if req.Role == models.ClubRoleOwner {
+		owner.Role = models.ClubRoleAdmin
+		updates = append(updates, owner)
+	}
+	if err := Repos.Clubs.UpdateMembers(updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newClubMemberResponse(member))
+}
+
+// RemoveClubMember lets members leave a club and club admins remove members.
+// Only the owner can remove admins, and the owner can only leave once they
+// are the last member, which deletes the club.
+func RemoveClubMember(c *gin.Context) {
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	userID := c.GetString("userID")
+	actor, ok := clubMembership(c, club.ID, userID)
+	if !ok {
+		return
+	}
+
+	if c.Param("user_id") == userID {
+		if actor.Role == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You are not a member of this club"})
+			return
+		}
+		if actor.Role == models.ClubRoleOwner {
+			members, err := Repos.Clubs.ListMembers(club.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+				return
+			}
+			if len(members) > 1 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Make another member the owner before leaving the club"})
+				return
+			}
+		}
+		if err := Repos.Clubs.RemoveMember(club.ID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave club"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "You left the club"})
+		return
+	}
+
+	if !actor.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only club admins can remove members"})
+		return
+	}
+	member, ok := clubMembership(c, club.ID, c.Param("user_id"))
+	if !ok {
+		return
+	}
+	if member.Role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if member.IsAdmin() && actor.Role != models.ClubRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the club owner can remove admins"})
+		return
+	}
+
+	if err := Repos.Clubs.RemoveMember(club.ID, member.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	notifyUser(member.UserID, fmt.Sprintf("You have been removed from %s", club.Name))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed from the club"})
+}
+
+// RequestToJoinClub asks a club's admins to let the signed-in user join. The
+// admins are told about the request.
+func RequestToJoinClub(c *gin.Context) {
+	// The body is optional: an empty one asks without a message
+	var req models.JoinClubRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	message, ok := clubText(c, "message", req.Message, maxJoinMessageLength, false)
+	if !ok {
+		return
+	}
+
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	userID := c.GetString("userID")
+	member, ok := clubMembership(c, club.ID, userID)
+	if !ok {
+		return
+	}
+	if member.Role != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this club"})
+		return
+	}
+
+	request := models.ClubJoinRequest{
+		ClubID:    club.ID,
+		UserID:    userID,
+		Message:   message,
+		Status:    models.JoinRequestPending,
+		CreatedAt: time.Now(),
+	}
+	if err := Repos.Clubs.RequestToJoin(request); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already asked to join this club"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request to join club"})
+		return
+	}
+
+	response := newJoinRequestResponse(request)
+	if members, err := Repos.Clubs.ListMembers(club.ID); err == nil {
+		for _, admin := range members {
+			if admin.IsAdmin() {
+				notifyUser(admin.UserID, fmt.Sprintf("%s asked to join %s", response.DisplayName, club.Name))
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListClubJoinRequests returns a club's pending join requests to its admins,
+// oldest first
+func ListClubJoinRequests(c *gin.Context) {
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if _, ok := requireClubAdmin(c, club.ID, "see join requests"); !ok {
+		return
+	}
+
+	requests, err := Repos.Clubs.ListJoinRequests(club.ID, models.JoinRequestPending)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load join requests"})
+		return
+	}
+
+	response := models.ClubJoinRequestListResponse{ClubID: club.ID, Requests: []models.ClubJoinRequestResponse{}}
+	for _, request := range requests {
+		response.Requests = append(response.Requests, newJoinRequestResponse(request))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ApproveJoinRequest lets club admins accept a pending join request, making
+// the user a member
+func ApproveJoinRequest(c *gin.Context) {
+	decideJoinRequest(c, models.JoinRequestApproved)
+}
+
+// RejectJoinRequest lets club admins turn down a pending join request
+func RejectJoinRequest(c *gin.Context) {
+	decideJoinRequest(c, models.JoinRequestRejected)
+}
+
+// decideJoinRequest records an admin's decision on a pending join request,
+// tells the user and writes the response
+func decideJoinRequest(c *gin.Context, status string) {
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	admin, ok := requireClubAdmin(c, club.ID, "decide on join requests")
+	if !ok {
+		return
+	}
+
+	request, err := Repos.Clubs.GetJoinRequest(club.ID, c.Param("user_id"))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load join request"})
+		return
+	}
+	if err != nil || request.Status != models.JoinRequestPending {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This user has no pending request to join the club"})
+		return
+	}
+
+	request.Status = status
+	request.DecidedBy = admin.UserID
+	request.DecidedAt = time.Now()
+	if err := Repos.Clubs.DecideJoinRequest(request); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "This user has no pending request to join the club"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save decision"})
+		return
+	}
+
+	decision := "approved"
+	if status == models.JoinRequestRejected {
+		decision = "declined"
+	}
+	notifyUser(request.UserID, fmt.Sprintf("Your request to join %s was %s", club.Name, decision))
+
+	c.JSON(http.StatusOK, newJoinRequestResponse(request))
+}
+
+// ClubGames lists the games a club owns. It accepts the query parameters of
+// ListGames; members-only games are left out for users outside the club.
+func ClubGames(c *gin.Context) {
+	club, ok := loadClub(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	filter, ok := parseGameFilter(c)
+	if !ok {
+		return
+	}
+
+	filter.ClubID = club.ID
+	filter.VisibleTo = gameViewer(c)
+
+	respondWithGamePage(c, filter)
+}
+
+// loadClub fetches a club by ID, writing a not found or error response if it
+// cannot be loaded
+func loadClub(c *gin.Context, clubID string) (models.Club, bool) {
+	club, err := Repos.Clubs.Get(clubID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Club not found"})
+		return models.Club{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load club"})
+		return models.Club{}, false
+	}
+	return club, true
+}
+
+// clubMembership returns a user's membership of a club, with an empty role if
+// they are not a member, writing an error response if it cannot be loaded
+func clubMembership(c *gin.Context, clubID, userID string) (models.ClubMember, bool) {
+	member, err := Repos.Clubs.GetMember(clubID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.ClubMember{ClubID: clubID, UserID: userID}, true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load club membership"})
+		return models.ClubMember{}, false
+	}
+	return member, true
+}
+
+// requireClubAdmin returns the signed-in user's membership of a club, writing
+// a forbidden response if they are not one of its admins. action completes
+// the error message.
+func requireClubAdmin(c *gin.Context, clubID, action string) (models.ClubMember, bool) {
+	member, ok := clubMembership(c, clubID, c.GetString("userID"))
+	if !ok {
+		return models.ClubMember{}, false
+	}
+	if !member.IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only club admins can " + action})
+		return models.ClubMember{}, false
+	}
+	return member, true
+}
+
+// clubText trims a club text field and checks its length, writing a bad
+// request response if it is missing or too long
+func clubText(c *gin.Context, field, value string, maxLength int, required bool) (string, bool) {
+	value = strings.TrimSpace(value)
+	if required && value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": field + " cannot be empty"})
+		return "", false
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be longer than %d characters", field, maxLength)})
+		return "", false
+	}
+	return value, true
+}
+
+// newClubResponse builds the representation of a club for the signed-in
+// user, writing an error response if their membership cannot be loaded
+func newClubResponse(c *gin.Context, club models.Club) (models.ClubResponse, bool) {
+	members, err := Repos.Clubs.ListMembers(club.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+		return models.ClubResponse{}, false
+	}
+
+	response := models.ClubResponse{
+		ID:          club.ID,
+		Name:        club.Name,
+		Description: club.Description,
+		MemberCount: len(members),
+		CreatedAt:   club.CreatedAt,
+	}
+	userID := c.GetString("userID")
+	for _, member := range members {
+		if member.UserID == userID {
+			response.Role = member.Role
+			return response, true
+		}
+	}
+
+	request, err := Repos.Clubs.GetJoinRequest(club.ID, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load join request"})
+		return models.ClubResponse{}, false
+	}
+	response.JoinRequest = request.Status
+	return response, true
+}
+
+// newClubMemberResponse builds a club member list entry
+func newClubMemberResponse(member models.ClubMember) models.ClubMemberResponse {
+	response := models.ClubMemberResponse{UserID: member.UserID, Role: member.Role, JoinedAt: member.JoinedAt}
+	if user, err := Repos.Users.GetByID(member.UserID); err == nil {
+		response.DisplayName = user.PublicName()
+	}
+	return response
+}
+
+// newJoinRequestResponse builds a join request list entry
+func newJoinRequestResponse(request models.ClubJoinRequest) models.ClubJoinRequestResponse {
+	response := models.ClubJoinRequestResponse{
+		UserID:    request.UserID,
+		Message:   request.Message,
+		Status:    request.Status,
+		CreatedAt: request.CreatedAt,
+	}
+	if user, err := Repos.Users.GetByID(request.UserID); err == nil {
+		response.DisplayName = user.PublicName()
+	}
+	return response
+}
+
+// canManageGame reports whether a user can edit, cancel and run a game: its
+// creator can, and so can the admins of the club that owns it
+func canManageGame(game models.Game, userID string) (bool, error) {
+	if game.CreatorID == userID {
+		return true, nil
+	}
+	if game.ClubID == "" {
+		return false, nil
+	}
+	member, err := Repos.Clubs.GetMember(game.ClubID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return member.IsAdmin(), nil
+}
+
+// requireGameManager writes a forbidden response unless the signed-in user
+// can manage a game. action completes the error message.
+func requireGameManager(c *gin.Context, game models.Game, action string) bool {
+	allowed, err := canManageGame(game, c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load club membership"})
+		return false
+	}
+	if !allowed {
+		managers := "the game creator"
+		if game.ClubID != "" {
+			managers = "the game creator and club admins"
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only " + managers + " can " + action})
+		return false
+	}
+	return true
+}
+
+// canSeeGame reports whether a user can see a game. Members-only games are
+// visible to their creator, the members of their club and the users on their
+// roster or waitlist.
+func canSeeGame(game models.Game, userID string) (bool, error) {
+	if !game.MembersOnly || game.CreatorID == userID {
+		return true, nil
+	}
+
+	_, err := Repos.Clubs.GetMember(game.ClubID, userID)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return false, err
+	}
+
+	participant, err := Repos.Participants.Get(game.ID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return participant.Status == models.ParticipantJoined || participant.Status == models.ParticipantWaitlisted, nil
+}
+
+// gameViewer returns the user whose view of members-only games a listing
+// follows, which is empty for admins as they can see every game
+func gameViewer(c *gin.Context) string {
+	if c.GetString("role") == models.RoleAdmin {
+		return ""
+	}
+	return c.GetString("userID")
+}
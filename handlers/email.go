@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// emailVerifyKey is the OTP key of a user's pending email address. Keying it
+// by user ties the code to the account that asked for it.
+func emailVerifyKey(userID string) string {
+	return "email-verify:" + userID
+}
+
+// emailLoginKey is the OTP key of login codes sent to an email address
+func emailLoginKey(email string) string {
+	return "email-login:" + email
+}
+
+// RequestEmail emails a code to the address the signed-in user wants to sign
+// in with. Addresses verified by another account are refused.
+func RequestEmail(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	email, err := utils.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+	if email == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This is already your email address"})
+		return
+	}
+	if !checkEmailAvailable(c, email) {
+		return
+	}
+
+	otp, ok := storeOTP(c, emailVerifyKey(user.ID), email)
+	if !ok {
+		return
+	}
+	body := fmt.Sprintf("Hello %s,\n\nYour code to confirm this email address is: %s\n\nIt expires in %d minutes. If you didn't ask for it, you can ignore this email.",
+		user.FirstName, otp, int(otpLifetime.Minutes()))
+	if err := Email.SendEmail(email, "Confirm your email address", body); err != nil {
+		log.Printf("Failed to send email verification to %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent to the email address"})
+}
+
+// VerifyEmail sets the signed-in user's email address once the code sent to
+// it is verified. A replaced address is told about the change.
+func VerifyEmail(c *gin.Context) {
+	var req models.EmailVerify
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+
+	data, ok := verifyOTP(c, emailVerifyKey(user.ID), req.OTP)
+	if !ok {
+		return
+	}
+
+	// The address is unique, so this fails if another account took it meanwhile
+	oldEmail := user.Email
+	user.Email = data.Target
+	user.UpdatedAt = time.Now()
+	if err := Repos.Users.Update(user); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This email address belongs to another account"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email address"})
+		return
+	}
+
+	if err := Repos.OTPs.Delete(emailVerifyKey(user.ID)); err != nil {
+		log.Printf("Failed to clear email OTP of user %s: %v", user.ID, err)
+	}
+
+	if oldEmail != "" {
+		body := fmt.Sprintf("The email address of your account was changed to %s. If this wasn't you, contact support.", user.Email)
+		if err := Email.SendEmail(oldEmail, "Your email address was changed", body); err != nil {
+			log.Printf("Failed to notify %s of email change: %v", oldEmail, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email address verified",
+		"user":    newUserResponse(user),
+	})
+}
+
+// RequestEmailLogin emails a login code, and a link if EmailLoginURL is set,
+// to a verified address. The response is the same whether or not the address
+// belongs to an account, so it cannot be used to find out who has one.
+func RequestEmailLogin(c *gin.Context) {
+	var req models.EmailLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	email, err := utils.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	user, err := Repos.Users.GetByEmail(email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	exists := err == nil
+
+	// A code is stored for unknown addresses too, so the resend cooldown
+	// applies to them alike. It is never sent, so it cannot be used.
+	otp, ok := storeOTP(c, emailLoginKey(email), "")
+	if !ok {
+		return
+	}
+
+	if exists {
+		body := fmt.Sprintf("Hello %s,\n\nYour login code is: %s\n", user.FirstName, otp)
+		if link := emailLoginLink(email, otp); link != "" {
+			body += "\nOr sign in with this link:\n" + link + "\n"
+		}
+		body += fmt.Sprintf("\nIt expires in %d minutes. If you didn't ask for it, you can ignore this email.", int(otpLifetime.Minutes()))
+		if err := Email.SendEmail(email, "Your login code", body); err != nil {
+			log.Printf("Failed to send login email to %s: %v", email, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the address belongs to an account, a login code has been sent to it"})
+}
+
+// VerifyEmailLogin signs in with a code from a login email. It starts a
+// session exactly like VerifyOTP does for a registered phone number.
+func VerifyEmailLogin(c *gin.Context) {
+	var req models.EmailLoginVerify
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	email, err := utils.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	if _, ok := verifyOTP(c, emailLoginKey(email), req.OTP); !ok {
+		return
+	}
+
+	user, err := Repos.Users.GetByEmail(email)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No account uses this email address"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	if user.IsSuspended() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended", "reason": user.SuspendedReason})
+		return
+	}
+
+	token, refreshToken, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	// Clear the attempt and lockout history after successful verification
+	if err := Repos.OTPs.Delete(emailLoginKey(email)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear OTP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(gin.H{"message": "Email verified successfully"}, token, refreshToken))
+}
+
+// emailLoginLink returns the login link for a code, or an empty string if
+// EmailLoginURL is not set
+func emailLoginLink(email, otp string) string {
+	if EmailLoginURL == "" {
+		return ""
+	}
+	separator := "?"
+	if strings.Contains(EmailLoginURL, "?") {
+		separator = "&"
+	}
+	return EmailLoginURL + separator + url.Values{"email": {email}, "code": {otp}}.Encode()
+}
+
+// checkEmailAvailable writes a conflict response if an address belongs to an account
+func checkEmailAvailable(c *gin.Context, email string) bool {
+	_, err := Repos.Users.GetByEmail(email)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This email address belongs to another account"})
+		return false
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email address"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+)
+
+// FollowUser makes the signed-in user follow another user. Followers are
+// told when the user creates a game.
+func FollowUser(c *gin.Context) {
+	userID := c.GetString("userID")
+	if c.Param("id") == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	followee, ok := loadActiveUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	follow := models.Follow{FollowerID: userID, FolloweeID: followee.ID, CreatedAt: time.Now()}
+	if err := Repos.Follows.Follow(follow); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already follow this user"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+
+	followers, ok := followSet(c, userID, Repos.Follows.ListFollowers)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.FollowResponse{
+		UserID:      followee.ID,
+		DisplayName: followee.PublicName(),
+		Friend:      followers[followee.ID],
+		Since:       follow.CreatedAt,
+	})
+}
+
+// UnfollowUser stops the signed-in user from following another user
+func UnfollowUser(c *gin.Context) {
+	err := Repos.Follows.Unfollow(c.GetString("userID"), c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You do not follow this user"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unfollowed"})
+}
+
+// ListFollowers returns the users following a user, most recent first
+func ListFollowers(c *gin.Context) {
+	user, ok := loadActiveUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	followers, err := Repos.Follows.ListFollowers(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load followers"})
+		return
+	}
+	following, ok := followSet(c, user.ID, Repos.Follows.ListFollowing)
+	if !ok {
+		return
+	}
+
+	response := models.FollowListResponse{UserID: user.ID, Users: []models.FollowResponse{}}
+	for _, follow := range followers {
+		response.Users = append(response.Users, newFollowResponse(follow.FollowerID, follow.CreatedAt, following[follow.FollowerID]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListFollowing returns the users a user follows, most recent first
+func ListFollowing(c *gin.Context) {
+	user, ok := loadActiveUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	following, err := Repos.Follows.ListFollowing(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load follows"})
+		return
+	}
+	followers, ok := followSet(c, user.ID, Repos.Follows.ListFollowers)
+	if !ok {
+		return
+	}
+
+	response := models.FollowListResponse{UserID: user.ID, Users: []models.FollowResponse{}}
+	for _, follow := range following {
+		response.Users = append(response.Users, newFollowResponse(follow.FolloweeID, follow.CreatedAt, followers[follow.FolloweeID]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListFriends returns the users who follow the signed-in user back, most
+// recent friendship first
+func ListFriends(c *gin.Context) {
+	userID := c.GetString("userID")
+	friends, ok := loadFriends(c, userID)
+	if !ok {
+		return
+	}
+
+	response := models.FollowListResponse{UserID: userID, Users: []models.FollowResponse{}}
+	for _, friend := range friends {
+		response.Users = append(response.Users, newFollowResponse(friend.FolloweeID, friend.CreatedAt, true))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// FollowingGames lists games created by the users the signed-in user
+// follows, leaving out members-only games of clubs they are not in. It
+// accepts the query parameters of ListGames, except that it defaults to
+// upcoming scheduled and confirmed games.
+func FollowingGames(c *gin.Context) {
+	filter, ok := parseGameFilter(c)
+	if !ok {
+		return
+	}
+
+	filter.FollowedBy = c.GetString("userID")
+	filter.VisibleTo = gameViewer(c)
+	if c.Query("status") == "" {
+		filter.Statuses = []string{models.GameScheduled, models.GameConfirmed}
+	}
+	if c.Query("start_after") == "" {
+		filter.StartAfter = time.Now()
+	}
+
+	respondWithGamePage(c, filter)
+}
+
+// InviteFriends lets the creator of a game, or an admin of the club that owns
+// it, invite their friends to it by text message. Friends already on the
+// roster or waitlist are skipped, as are friends outside the club for
+// members-only games.
+func InviteFriends(c *gin.Context) {
+	// The body is optional: an empty one invites every friend
+	var req models.GameInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	userID := c.GetString("userID")
+	if !requireGameManager(c, game, "invite friends") {
+		return
+	}
+	if !game.IsOpen() || !game.StartTime.After(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only upcoming games can be invited to"})
+		return
+	}
+
+	friends, ok := loadFriends(c, userID)
+	if !ok {
+		return
+	}
+	isFriend := make(map[string]bool, len(friends))
+	var invitees []string
+	for _, friend := range friends {
+		isFriend[friend.FolloweeID] = true
+		invitees = append(invitees, friend.FolloweeID)
+	}
+	if req.UserIDs != nil {
+		invitees = nil
+		for _, inviteeID := range req.UserIDs {
+			if !isFriend[inviteeID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "User " + inviteeID + " is not your friend"})
+				return
+			}
+			invitees = append(invitees, inviteeID)
+		}
+	}
+
+	participants, err := Repos.Participants.ListByGame(game.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participants"})
+		return
+	}
+	onRoster := make(map[string]bool)
+	for _, participant := range participants {
+		if participant.Status == models.ParticipantJoined || participant.Status == models.ParticipantWaitlisted {
+			onRoster[participant.UserID] = true
+		}
+	}
+
+	inviter, ok := loadUser(c, userID)
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("%s invited you to %s on %s at %s. Game ID: %s",
+		inviter.PublicName(), game.EventName, game.StartTime.Format("Mon 2 Jan 15:04"), game.Location, game.ID)
+
+	response := models.GameInviteResponse{GameID: game.ID, Invited: []string{}}
+	invited := make(map[string]bool)
+	for _, inviteeID := range invitees {
+		if onRoster[inviteeID] || invited[inviteeID] {
+			continue
+		}
+		if visible, err := canSeeGame(game, inviteeID); err != nil || !visible {
+			continue
+		}
+		invited[inviteeID] = true
+		response.Invited = append(response.Invited, inviteeID)
+	}
+
+	c.JSON(http.StatusOK, response)
+
+	// Invites are texted after responding; failures are logged by notifyUser
+	go func() {
+		for _, inviteeID := range response.Invited {
+			notifyUser(inviteeID, message)
+		}
+	}()
+}
+
+// notifyFollowers tells the followers of a game's creator about a new game.
+// Followers who cannot see a members-only game are not told. Failures are
+// logged since it runs after the game has been created.
+func notifyFollowers(game models.Game) {
+	creator, err := Repos.Users.GetByID(game.CreatorID)
+	if err != nil {
+		log.Printf("Failed to load creator of game %s: %v", game.ID, err)
+		return
+	}
+	followers, err := Repos.Follows.ListFollowers(game.CreatorID)
+	if err != nil {
+		log.Printf("Failed to load followers of user %s: %v", game.CreatorID, err)
+		return
+	}
+
+	message := fmt.Sprintf("%s created %s on %s at %s. Game ID: %s",
+		creator.PublicName(), game.EventName, game.StartTime.Format("Mon 2 Jan 15:04"), game.Location, game.ID)
+	for _, follow := range followers {
+		visible, err := canSeeGame(game, follow.FollowerID)
+		if err != nil {
+			log.Printf("Failed to check whether user %s can see game %s: %v", follow.FollowerID, game.ID, err)
+			continue
+		}
+		if visible {
+			notifyUser(follow.FollowerID, message)
+		}
+	}
+}
+
+// loadActiveUser fetches a user who has not deleted their account, writing a
+// not found or error response if there is none
+func loadActiveUser(c *gin.Context, userID string) (models.User, bool) {
+	user, err := Repos.Users.GetByID(userID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && user.IsDeleted()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return models.User{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// followSet returns the IDs of the other users in a user's followers or
+// following list, writing an error response if it cannot be loaded
+func followSet(c *gin.Context, userID string, list func(string) ([]models.Follow, error)) (map[string]bool, bool) {
+	follows, err := list(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load follows"})
+		return nil, false
+	}
+
+	set := make(map[string]bool, len(follows))
+	for _, follow := range follows {
+		if follow.FollowerID == userID {
+			set[follow.FolloweeID] = true
+		} else {
+			set[follow.FollowerID] = true
+		}
+	}
+	return set, true
+}
+
+// loadFriends returns a user's follows of the users who follow them back,
+// dated when the second of the two follows was made and most recent first
+func loadFriends(c *gin.Context, userID string) ([]models.Follow, bool) {
+	following, err := Repos.Follows.ListFollowing(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load follows"})
+		return nil, false
+	}
+	followers, err := Repos.Follows.ListFollowers(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load followers"})
+		return nil, false
+	}
+
+	followedBack := make(map[string]time.Time, len(followers))
+	for _, follow := range followers {
+		followedBack[follow.FollowerID] = follow.CreatedAt
+	}
+	var friends []models.Follow
+	for _, follow := range following {
+		followedAt, exists := followedBack[follow.FolloweeID]
+		if !exists {
+			continue
+		}
+		if followedAt.After(follow.CreatedAt) {
+			follow.CreatedAt = followedAt
+		}
+		friends = append(friends, follow)
+	}
+	sort.SliceStable(friends, func(i, j int) bool {
+		return friends[i].CreatedAt.After(friends[j].CreatedAt)
+	})
+	return friends, true
+}
+
+// newFollowResponse builds a follower list entry
+func newFollowResponse(userID string, since time.Time, friend bool) models.FollowResponse {
+	response := models.FollowResponse{UserID: userID, Friend: friend, Since: since}
+	if user, err := Repos.Users.GetByID(userID); err == nil {
+		response.DisplayName = user.PublicName()
+	}
+	return response
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+)
+
+func TestFollowingGamesHidesMembersOnlyGamesFromNonMembers(t *testing.T) {
+	repos := useMemoryRepos(t)
+	now := time.Now()
+	for i, id := range []string{"owner", "fan"} {
+		user := models.User{ID: id, FirstName: id, Phone: "+1415555010" + string(rune('0'+i)), Role: models.RolePlayer, CreatedAt: now, UpdatedAt: now}
+		if err := repos.Users.Create(user); err != nil {
+			t.Fatalf("create user %s: %v", id, err)
+		}
+	}
+	club := models.Club{ID: "club", Name: "Sunday Crew", CreatedAt: now, UpdatedAt: now}
+	owner := models.ClubMember{ClubID: "club", UserID: "owner", Role: models.ClubRoleOwner, JoinedAt: now}
+	if err := repos.Clubs.Create(club, owner); err != nil {
+		t.Fatalf("create club: %v", err)
+	}
+	storeGame(t, repos, models.Game{ID: "open", ClubID: "club"}, "owner")
+	storeGame(t, repos, models.Game{ID: "private", ClubID: "club", MembersOnly: true}, "owner")
+	if err := repos.Follows.Follow(models.Follow{FollowerID: "fan", FolloweeID: "owner", CreatedAt: now}); err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/games/following", func(c *gin.Context) {
+		c.Set("userID", "fan")
+		c.Set("role", models.RolePlayer)
+	}, FollowingGames)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/games/following", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.GameListResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(response.Games) != 1 || response.Games[0].ID != "open" {
+		t.Errorf("following feed %+v, want only the open club game", response.Games)
+	}
+}
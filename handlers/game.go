@@ -1,18 +1,25 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	
 	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
 )
 
-// In-memory storage for games (would be replaced with a database in production)
-var games = make(map[string]models.Game)
-
 // CreateGame handles the creation of a new game
 func CreateGame(c *gin.Context) {
 	// Get user ID from JWT claims
@@ -29,27 +36,19 @@ func CreateGame(c *gin.Context) {
 		return
 	}
 	
-	// Parse time strings
-	startTime, err := time.Parse(time.RFC3339, req.StartTime)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format. Use YYYY-MM-DDThh:mm:ssZ"})
+	// Parse and validate time strings
+	startTime, endTime, ok := parseGameTimes(c, req.StartTime, req.EndTime)
+	if !ok {
 		return
 	}
-	
-	endTime, err := time.Parse(time.RFC3339, req.EndTime)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format. Use YYYY-MM-DDThh:mm:ssZ"})
+	if !validateCoordinates(c, req.Coordinates) {
 		return
 	}
-	
-	// Validate times
-	if startTime.Before(time.Now()) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Start time cannot be in the past"})
+	if !validateGameCapacity(c, req.CostPerPerson, req.PlayerRequirement) {
 		return
 	}
 	
-	if endTime.Before(startTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+	if !validateGameClub(c, req.ClubID, req.MembersOnly, "create games for this club") {
 		return
 	}
 	
@@ -63,84 +62,58 @@ func CreateGame(c *gin.Context) {
 		StartTime:           startTime,
 		EndTime:             endTime,
 		Location:            req.Location,
+		Coordinates:         req.Coordinates,
 		CostPerPerson:       req.CostPerPerson,
 		PlayerRequirement:   req.PlayerRequirement,
 		CurrentParticipants: 0, // Initially no participants
 		CreatorID:           userID.(string),
+		Status:              models.GameScheduled,
+		ClubID:              req.ClubID,
+		MembersOnly:         req.MembersOnly,
 		CreatedAt:           now,
 		UpdatedAt:           now,
 	}
 	
-	// Save game (in a real app, this would be in a database)
-	games[gameID] = game
+	// Save game
+	if err := Repos.Games.Create(game); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create game"})
+		return
+	}
 	
 	// Return response
-	c.JSON(http.StatusCreated, models.GameResponse{
-		ID:                  game.ID,
-		EventName:           game.EventName,
-		StartTime:           game.StartTime,
-		EndTime:             game.EndTime,
-		Location:            game.Location,
-		CostPerPerson:       game.CostPerPerson,
-		PlayerRequirement:   game.PlayerRequirement,
-		CurrentParticipants: game.CurrentParticipants,
-		CreatorID:           game.CreatorID,
-		CreatedAt:           game.CreatedAt,
-	})
+	c.JSON(http.StatusCreated, newGameResponse(game))
+	
+	// Followers are texted after responding so a creator with many followers
+	// does not wait on the SMS provider
+	go notifyFollowers(game)
 }
 
 // GetGame retrieves a specific game by ID
 func GetGame(c *gin.Context) {
-	gameID := c.Param("id")
-	
-	game, exists := games[gameID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
 		return
 	}
 	
-	c.JSON(http.StatusOK, models.GameResponse{
-		ID:                  game.ID,
-		EventName:           game.EventName,
-		StartTime:           game.StartTime,
-		EndTime:             game.EndTime,
-		Location:            game.Location,
-		CostPerPerson:       game.CostPerPerson,
-		PlayerRequirement:   game.PlayerRequirement,
-		CurrentParticipants: game.CurrentParticipants,
-		CreatorID:           game.CreatorID,
-		CreatedAt:           game.CreatedAt,
-	})
+	c.JSON(http.StatusOK, newGameResponse(game))
 }
 
-// ListGames returns all available games
+// ListGames returns a page of games. See parseGameFilter for the supported
+// query parameters.
 func ListGames(c *gin.Context) {
-	var gameList []models.GameResponse
-	
-	for _, game := range games {
-		gameList = append(gameList, models.GameResponse{
-			ID:                  game.ID,
-			EventName:           game.EventName,
-			StartTime:           game.StartTime,
-			EndTime:             game.EndTime,
-			Location:            game.Location,
-			CostPerPerson:       game.CostPerPerson,
-			PlayerRequirement:   game.PlayerRequirement,
-			CurrentParticipants: game.CurrentParticipants,
-			CreatorID:           game.CreatorID,
-			CreatedAt:           game.CreatedAt,
-		})
+	filter, ok := parseGameFilter(c)
+	if !ok {
+		return
 	}
+	filter.VisibleTo = gameViewer(c)
 	
-	c.JSON(http.StatusOK, models.GameListResponse{
-		Games: gameList,
-	})
+	respondWithGamePage(c, filter)
 }
 
 // JoinGame allows a user to join a game
 func JoinGame(c *gin.Context) {
 	// Get user ID from JWT claims
-	_, exists := c.Get("userID")
+	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -154,17 +127,37 @@ func JoinGame(c *gin.Context) {
 	}
 	
 	// Get game
-	game, exists := games[req.GameID]
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+	game, ok := loadGame(c, req.GameID)
+	if !ok {
+		return
+	}
+	
+	// Only scheduled and confirmed games accept new players
+	if !game.IsOpen() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game is " + game.Status + " and can no longer be joined"})
 		return
 	}
 	
-	// Check if game is full
-	if game.CurrentParticipants >= game.PlayerRequirement {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game is already full"})
+	// Reject duplicate joins and users the creator has removed; users who
+	// left earlier may join again
+	existing, err := Repos.Participants.Get(game.ID, userID.(string))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participant"})
 		return
 	}
+	if err == nil {
+		switch existing.Status {
+		case models.ParticipantRemoved:
+			c.JSON(http.StatusForbidden, gin.H{"error": "You have been removed from this game"})
+			return
+		case models.ParticipantJoined:
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already joined this game"})
+			return
+		case models.ParticipantWaitlisted:
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already on the waitlist for this game"})
+			return
+		}
+	}
 	
 	// Check if game has already started
 	if game.StartTime.Before(time.Now()) {
@@ -172,51 +165,774 @@ func JoinGame(c *gin.Context) {
 		return
 	}
 	
-	// Update participant count (in a real app, we would add the user to a participants list)
-	game.CurrentParticipants++
-	game.UpdatedAt = time.Now()
-	games[req.GameID] = game
+	// Add the user to the roster, or to the waitlist if the game is full. The
+	// capacity check happens inside the repository so concurrent joins cannot
+	// overbook the game.
+	participant, err := Repos.Participants.Join(game.ID, userID.(string), time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already joined this game"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join game"})
+		return
+	}
+	
+	if participant.Status == models.ParticipantWaitlisted {
+		position, err := waitlistPosition(game.ID, participant.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load waitlist"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":  "Game is full, you have been added to the waitlist",
+			"position": position,
+		})
+		return
+	}
+	if refreshed, err := Repos.Games.Get(game.ID); err == nil {
+		game = refreshed
+	}
 	
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Successfully joined the game",
-		"game": models.GameResponse{
-			ID:                  game.ID,
-			EventName:           game.EventName,
-			StartTime:           game.StartTime,
-			EndTime:             game.EndTime,
-			Location:            game.Location,
-			CostPerPerson:       game.CostPerPerson,
-			PlayerRequirement:   game.PlayerRequirement,
-			CurrentParticipants: game.CurrentParticipants,
-			CreatorID:           game.CreatorID,
-			CreatedAt:           game.CreatedAt,
-		},
+		"game": newGameResponse(game),
 	})
 }
 
-// PublicListGames returns all available games without requiring authentication
+// PublicListGames returns a page of upcoming games without requiring
+// authentication. Cancelled games are hidden unless explicitly requested
+// through the status query parameter, and members-only club games are never
+// shown.
 func PublicListGames(c *gin.Context) {
-	var gameList []models.GameResponse
+	filter, ok := parseGameFilter(c)
+	if !ok {
+		return
+	}
+	filter.PublicOnly = true
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []string{models.GameScheduled, models.GameConfirmed}
+	}
+	
+	// Only include games that haven't started yet
+	if now := time.Now(); filter.StartAfter.Before(now) {
+		filter.StartAfter = now
+	}
+	
+	respondWithGamePage(c, filter)
+}
+
+// Search radius limits for nearby games, in kilometres
+const (
+	defaultNearbyRadiusKm = 10.0
+	maxNearbyRadiusKm     = 200.0
+)
+
+// NearbyGames returns upcoming games within radius_km of the lat and lng query
+// parameters, nearest first. Games without coordinates are never included.
+func NearbyGames(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required decimal degrees"})
+		return
+	}
+	center := models.GeoPoint{Latitude: lat, Longitude: lng}
+	if err := center.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	radius := defaultNearbyRadiusKm
+	if value := c.Query("radius_km"); value != "" {
+		var err error
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("radius_km must be greater than 0 and at most %g", maxNearbyRadiusKm)})
+			return
+		}
+	}
+	
+	limit := defaultGamePageSize
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxGamePageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxGamePageSize)})
+			return
+		}
+	}
+	
+	// The geohash cells bound the candidates; the exact distance filters them
+	games, err := Repos.Games.Search(models.GameFilter{
+		Statuses:        []string{models.GameScheduled, models.GameConfirmed},
+		StartAfter:      time.Now(),
+		GeohashPrefixes: utils.GeohashCover(lat, lng, radius),
+		VisibleTo:       gameViewer(c),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search games"})
+		return
+	}
 	
+	nearby := []models.NearbyGameResponse{}
 	for _, game := range games {
-		// Only include games that haven't started yet
-		if !game.StartTime.Before(time.Now()) {
-			gameList = append(gameList, models.GameResponse{
-				ID:                  game.ID,
-				EventName:           game.EventName,
-				StartTime:           game.StartTime,
-				EndTime:             game.EndTime,
-				Location:            game.Location,
-				CostPerPerson:       game.CostPerPerson,
-				PlayerRequirement:   game.PlayerRequirement,
-				CurrentParticipants: game.CurrentParticipants,
-				CreatorID:           game.CreatorID,
-				CreatedAt:           game.CreatedAt,
-			})
-		}
-	}
-	
-	c.JSON(http.StatusOK, models.GameListResponse{
-		Games: gameList,
+		if game.Coordinates == nil {
+			continue
+		}
+		distance := utils.DistanceKm(lat, lng, game.Coordinates.Latitude, game.Coordinates.Longitude)
+		if distance <= radius {
+			nearby = append(nearby, models.NearbyGameResponse{GameResponse: newGameResponse(game), DistanceKm: distance})
+		}
+	}
+	
+	// Games come back in start time order, so equally distant games stay in that order
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+	if len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	
+	c.JSON(http.StatusOK, models.NearbyGamesResponse{Games: nearby})
+}
+
+// respondWithGamePage writes the games matching a filter together with the
+// cursor of the next page, if there is one
+func respondWithGamePage(c *gin.Context, filter models.GameFilter) {
+	// Fetch one extra game to find out whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	
+	games, err := Repos.Games.Search(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list games"})
+		return
+	}
+	
+	response := models.GameListResponse{Games: []models.GameResponse{}}
+	if len(games) > limit {
+		games = games[:limit]
+		last := games[len(games)-1]
+		response.NextCursor = encodeGameCursor(models.GameCursor{
+			SortBy:     filter.SortBy,
+			Descending: filter.Descending,
+			StartTime:  last.StartTime,
+			Cost:       last.CostPerPerson,
+			ID:         last.ID,
+		})
+	}
+	for _, game := range games {
+		response.Games = append(response.Games, newGameResponse(game))
+	}
+	
+	c.JSON(http.StatusOK, response)
+}
+
+// parseGameTimes parses RFC3339 start and end times and checks that the game
+// starts in the future and ends after it starts, writing a bad request
+// response if not
+func parseGameTimes(c *gin.Context, start, end string) (time.Time, time.Time, bool) {
+	startTime, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format. Use YYYY-MM-DDThh:mm:ssZ"})
+		return time.Time{}, time.Time{}, false
+	}
+	
+	endTime, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format. Use YYYY-MM-DDThh:mm:ssZ"})
+		return time.Time{}, time.Time{}, false
+	}
+	
+	// Validate times
+	if startTime.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start time cannot be in the past"})
+		return time.Time{}, time.Time{}, false
+	}
+	
+	if endTime.Before(startTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+		return time.Time{}, time.Time{}, false
+	}
+	
+	return startTime, endTime, true
+}
+
+// loadGame fetches a game by ID, writing a not found or server error response if it cannot be loaded.
+// Members-only club games the signed-in user cannot see are reported as not found.
+func loadGame(c *gin.Context, gameID string) (models.Game, bool) {
+	game, err := Repos.Games.Get(gameID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+		return models.Game{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game"})
+		return models.Game{}, false
+	}
+	if viewer := gameViewer(c); viewer != "" {
+		visible, err := canSeeGame(game, viewer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game"})
+			return models.Game{}, false
+		}
+		if !visible {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+			return models.Game{}, false
+		}
+	}
+	return game, true
+}
+
+// UpdateGame lets the creator or club admins edit a game's details. Every changed field is
+// recorded in the game's history and the roster is notified.
+func UpdateGame(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GameUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, game, "edit this game") {
+		return
+	}
+
+	if !game.IsOpen() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game is " + game.Status + " and can no longer be edited"})
+		return
+	}
+
+	updated, ok := applyGameUpdate(c, game, req)
+	if !ok {
+		return
+	}
+
+	updated, changes, err := saveGameUpdate(game, updated, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update game"})
+		return
+	}
+	if len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "No changes",
+			"game":    newGameResponse(game),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Game updated",
+		"game":    newGameResponse(updated),
+		"changes": changes,
 	})
 }
+
+// applyGameUpdate returns the game with the requested edits applied, writing a
+// bad request response if the result is not a valid game
+func applyGameUpdate(c *gin.Context, game models.Game, req models.GameUpdateRequest) (models.Game, bool) {
+	updated := game
+	if req.EventName != nil {
+		if *req.EventName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Event name cannot be empty"})
+			return game, false
+		}
+		updated.EventName = *req.EventName
+	}
+
+	if req.Location != nil {
+		if *req.Location == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Location cannot be empty"})
+			return game, false
+		}
+		updated.Location = *req.Location
+	}
+
+	if req.Coordinates != nil {
+		if !validateCoordinates(c, req.Coordinates) {
+			return game, false
+		}
+		updated.Coordinates = req.Coordinates
+	}
+
+	if req.CostPerPerson != nil {
+		updated.CostPerPerson = *req.CostPerPerson
+	}
+
+	// Re-run the creation time checks against the resulting start and end times
+	if req.StartTime != nil || req.EndTime != nil {
+		start := game.StartTime.Format(time.RFC3339)
+		if req.StartTime != nil {
+			start = *req.StartTime
+		}
+		end := game.EndTime.Format(time.RFC3339)
+		if req.EndTime != nil {
+			end = *req.EndTime
+		}
+
+		var ok bool
+		updated.StartTime, updated.EndTime, ok = parseGameTimes(c, start, end)
+		if !ok {
+			return game, false
+		}
+	}
+
+	if req.MembersOnly != nil {
+		if *req.MembersOnly && game.ClubID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only club games can be members-only"})
+			return game, false
+		}
+		updated.MembersOnly = *req.MembersOnly
+	}
+
+	if req.PlayerRequirement != nil {
+		updated.PlayerRequirement = *req.PlayerRequirement
+	}
+	if !validateGameCapacity(c, updated.CostPerPerson, updated.PlayerRequirement) {
+		return game, false
+	}
+
+	if req.PlayerRequirement != nil {
+		if *req.PlayerRequirement < game.CurrentParticipants {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(
+				"Player requirement cannot be lower than the %d players already on the roster", game.CurrentParticipants)})
+			return game, false
+		}
+	}
+
+	return updated, true
+}
+
+// validateGameCapacity checks the cost per person and player requirement of a
+// new or edited game, writing a bad request response if either is out of range
+func validateGameCapacity(c *gin.Context, costPerPerson float64, playerRequirement int) bool {
+	if costPerPerson < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cost per person cannot be negative"})
+		return false
+	}
+	if playerRequirement < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Player requirement must be at least 1"})
+		return false
+	}
+	return true
+}
+
+// validateGameClub checks the club a new game or series is created for: only
+// the club's admins can create them, and only club games can be members-only.
+// It writes the error response if the check fails.
+func validateGameClub(c *gin.Context, clubID string, membersOnly bool, action string) bool {
+	if clubID == "" {
+		if membersOnly {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only club games can be members-only"})
+			return false
+		}
+		return true
+	}
+	if _, ok := loadClub(c, clubID); !ok {
+		return false
+	}
+	_, ok := requireClubAdmin(c, clubID, action)
+	return ok
+}
+
+// saveGameUpdate stores an edited game, records the changed fields in its
+// history, promotes waitlisted users into any new spots and notifies the
+// roster. It returns the saved game and its changes, which are empty if
+// nothing differed.
+func saveGameUpdate(before, after models.Game, userID string) (models.Game, []models.GameChange, error) {
+	now := time.Now()
+	changes := diffGames(before, after, userID, now)
+	if len(changes) == 0 {
+		return before, nil, nil
+	}
+
+	after.Sequence++
+	after.UpdatedAt = now
+	if err := Repos.Games.Update(after); err != nil {
+		return before, nil, err
+	}
+
+	if err := Repos.History.Record(changes); err != nil {
+		log.Printf("Failed to record history for game %s: %v", after.ID, err)
+	}
+
+	// A larger game may have room for people on the waitlist
+	if after.PlayerRequirement > before.PlayerRequirement {
+		promoteFromWaitlist(after)
+		if refreshed, err := Repos.Games.Get(after.ID); err == nil {
+			after = refreshed
+		}
+	}
+
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, strings.ReplaceAll(change.Field, "_", " "))
+	}
+	notifyRoster(after.ID, fmt.Sprintf("%s on %s has been updated (%s). Check the game for details.",
+		after.EventName, after.StartTime.Format("Mon 2 Jan 15:04"), strings.Join(fields, ", ")))
+
+	return after, changes, nil
+}
+
+// GetGameHistory returns the edits made to a game. Only the users who can
+// manage the game and users on the roster or waitlist can see it.
+func GetGameHistory(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	canManage, err := canManageGame(game, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load club membership"})
+		return
+	}
+	if !canManage {
+		participant, err := Repos.Participants.Get(game.ID, userID.(string))
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participant"})
+			return
+		}
+		if err != nil || (participant.Status != models.ParticipantJoined && participant.Status != models.ParticipantWaitlisted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the organizers and participants can view this game's history"})
+			return
+		}
+	}
+
+	changes, err := Repos.History.ListByGame(game.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game history"})
+		return
+	}
+	if changes == nil {
+		changes = []models.GameChange{}
+	}
+
+	c.JSON(http.StatusOK, models.GameHistoryResponse{
+		GameID:  game.ID,
+		Changes: changes,
+	})
+}
+
+// CancelGame lets the creator or club admins cancel a game with a reason.
+// Participants and waitlisted users are notified.
+func CancelGame(c *gin.Context) {
+	var req models.CancelGameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A cancellation reason is required"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, game, "cancel this game") {
+		return
+	}
+
+	cancelGame(c, game, req.Reason)
+}
+
+// cancelGame cancels a game, notifies its roster and writes the response
+func cancelGame(c *gin.Context, game models.Game, reason string) {
+	game.CancelReason = reason
+	game, ok := transitionGame(c, game, models.GameCancelled)
+	if !ok {
+		return
+	}
+
+	notifyRoster(game.ID, fmt.Sprintf("%s on %s has been cancelled: %s",
+		game.EventName, game.StartTime.Format("Mon 2 Jan 15:04"), game.CancelReason))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Game cancelled",
+		"game":    newGameResponse(game),
+	})
+}
+
+// ConfirmGame lets the creator or club admins confirm that a scheduled game will go ahead
+func ConfirmGame(c *gin.Context) {
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, game, "confirm this game") {
+		return
+	}
+
+	game, ok = transitionGame(c, game, models.GameConfirmed)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Game confirmed",
+		"game":    newGameResponse(game),
+	})
+}
+
+// StartGameCompletion periodically marks games whose end time has passed as completed
+func StartGameCompletion(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if completed, err := Repos.Games.CompleteEnded(time.Now()); err != nil {
+				log.Printf("Failed to complete ended games: %v", err)
+			} else if completed > 0 {
+				log.Printf("Marked %d ended games as completed", completed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// transitionGame moves a game to a new status and saves it, writing a conflict
+// response if the transition is not allowed
+func transitionGame(c *gin.Context, game models.Game, status string) (models.Game, bool) {
+	if err := models.ValidateGameTransition(game.Status, status); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return game, false
+	}
+
+	game.Status = status
+	game.Sequence++
+	game.UpdatedAt = time.Now()
+	if err := Repos.Games.Update(game); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update game"})
+		return game, false
+	}
+	return game, true
+}
+
+// diffGames lists the editable fields that differ between two versions of a game
+func diffGames(before, after models.Game, changedBy string, at time.Time) []models.GameChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"event_name", before.EventName, after.EventName},
+		{"start_time", before.StartTime.Format(time.RFC3339), after.StartTime.Format(time.RFC3339)},
+		{"end_time", before.EndTime.Format(time.RFC3339), after.EndTime.Format(time.RFC3339)},
+		{"location", before.Location, after.Location},
+		{"coordinates", formatCoordinates(before.Coordinates), formatCoordinates(after.Coordinates)},
+		{"cost_per_person", strconv.FormatFloat(before.CostPerPerson, 'f', -1, 64), strconv.FormatFloat(after.CostPerPerson, 'f', -1, 64)},
+		{"player_requirement", strconv.Itoa(before.PlayerRequirement), strconv.Itoa(after.PlayerRequirement)},
+		{"members_only", strconv.FormatBool(before.MembersOnly), strconv.FormatBool(after.MembersOnly)},
+	}
+
+	var changes []models.GameChange
+	for _, field := range fields {
+		if field.old == field.new {
+			continue
+		}
+		changes = append(changes, models.GameChange{
+			GameID:    before.ID,
+			Field:     field.name,
+			OldValue:  field.old,
+			NewValue:  field.new,
+			ChangedBy: changedBy,
+			ChangedAt: at,
+		})
+	}
+	return changes
+}
+
+// validateCoordinates checks optional game coordinates, writing a bad request
+// response if they are out of range
+func validateCoordinates(c *gin.Context, point *models.GeoPoint) bool {
+	if point == nil {
+		return true
+	}
+	if err := point.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// formatCoordinates renders optional coordinates for the change history
+func formatCoordinates(point *models.GeoPoint) string {
+	if point == nil {
+		return ""
+	}
+	return strconv.FormatFloat(point.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(point.Longitude, 'f', -1, 64)
+}
+
+// Page sizes for game listings
+const (
+	defaultGamePageSize = 20
+	maxGamePageSize     = 100
+)
+
+// parseGameFilter builds a game filter from the query parameters of a listing
+// request, writing a bad request response if any of them is invalid:
+//
+//	status        comma-separated game statuses
+//	start_after   games starting at or after this RFC3339 time
+//	start_before  games starting before this RFC3339 time
+//	location      case-insensitive substring of the location
+//	max_cost      maximum cost per person
+//	open_spots    "true" to only include games that are not full
+//	creator       ID of the user who created the games
+//	club          ID of the club that owns the games
+//	sort          start_time (default) or cost
+//	order         asc (default) or desc
+//	limit         page size, 1 to 100 (default 20)
+//	cursor        next_cursor of the previous page
+func parseGameFilter(c *gin.Context) (models.GameFilter, bool) {
+	filter := models.GameFilter{
+		Location:  strings.TrimSpace(c.Query("location")),
+		CreatorID: c.Query("creator"),
+		ClubID:    c.Query("club"),
+		SortBy:    c.DefaultQuery("sort", models.SortByStartTime),
+		Limit:     defaultGamePageSize,
+	}
+	
+	statuses, err := parseStatusFilter(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.GameFilter{}, false
+	}
+	filter.Statuses = statuses
+	
+	for param, bound := range map[string]*time.Time{"start_after": &filter.StartAfter, "start_before": &filter.StartBefore} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		if *bound, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s format. Use YYYY-MM-DDThh:mm:ssZ", param)})
+			return models.GameFilter{}, false
+		}
+	}
+	
+	if value := c.Query("max_cost"); value != "" {
+		maxCost, err := strconv.ParseFloat(value, 64)
+		if err != nil || maxCost < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_cost must be a non-negative number"})
+			return models.GameFilter{}, false
+		}
+		filter.MaxCost = &maxCost
+	}
+	
+	if value := c.Query("open_spots"); value != "" {
+		if filter.HasOpenSpots, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "open_spots must be true or false"})
+			return models.GameFilter{}, false
+		}
+	}
+	
+	if filter.SortBy != models.SortByStartTime && filter.SortBy != models.SortByCost {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be start_time or cost"})
+		return models.GameFilter{}, false
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return models.GameFilter{}, false
+	}
+	
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxGamePageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxGamePageSize)})
+			return models.GameFilter{}, false
+		}
+		filter.Limit = limit
+	}
+	
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeGameCursor(value)
+		if err != nil || cursor.SortBy != filter.SortBy || cursor.Descending != filter.Descending {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor for this sort order"})
+			return models.GameFilter{}, false
+		}
+		filter.After = &cursor
+	}
+	
+	return filter, true
+}
+
+// parseStatusFilter parses a comma-separated list of game statuses.
+// An empty filter returns nil, meaning every status is accepted.
+func parseStatusFilter(filter string) ([]string, error) {
+	if filter == "" {
+		return nil, nil
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(filter, ",") {
+		status = strings.TrimSpace(status)
+		if !models.IsValidGameStatus(status) {
+			return nil, fmt.Errorf("unknown game status %q", status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// encodeGameCursor serializes a cursor into an opaque URL-safe token
+func encodeGameCursor(cursor models.GameCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeGameCursor parses a token produced by encodeGameCursor
+func decodeGameCursor(token string) (models.GameCursor, error) {
+	var cursor models.GameCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// newGameResponse builds the API representation of a game
+func newGameResponse(game models.Game) models.GameResponse {
+	return models.GameResponse{
+		ID:                  game.ID,
+		EventName:           game.EventName,
+		StartTime:           game.StartTime,
+		EndTime:             game.EndTime,
+		Location:            game.Location,
+		Coordinates:         game.Coordinates,
+		CostPerPerson:       game.CostPerPerson,
+		PlayerRequirement:   game.PlayerRequirement,
+		CurrentParticipants: game.CurrentParticipants,
+		CreatorID:           game.CreatorID,
+		Status:              game.Status,
+		CancelReason:        game.CancelReason,
+		SeriesID:            game.SeriesID,
+		ClubID:              game.ClubID,
+		MembersOnly:         game.MembersOnly,
+		CreatedAt:           game.CreatedAt,
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+)
+
+// ListParticipants returns the roster of a game
+func ListParticipants(c *gin.Context) {
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	participants, err := Repos.Participants.ListByGame(game.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participants"})
+		return
+	}
+
+	roster := []models.ParticipantResponse{}
+	for _, participant := range participants {
+		if participant.Status != models.ParticipantJoined {
+			continue
+		}
+		roster = append(roster, newParticipantResponse(participant))
+	}
+
+	c.JSON(http.StatusOK, models.ParticipantListResponse{
+		GameID:       game.ID,
+		Participants: roster,
+	})
+}
+
+// RemoveParticipant lets the creator of a game, or the admins of the club that
+// owns it, remove a participant from its roster
+func RemoveParticipant(c *gin.Context) {
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, game, "remove participants") {
+		return
+	}
+
+	if !game.IsOpen() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game is " + game.Status + " and its roster can no longer change"})
+		return
+	}
+
+	err := Repos.Participants.Transition(game.ID, c.Param("user_id"), models.ParticipantJoined, models.ParticipantRemoved, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Participant not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove participant"})
+		return
+	}
+
+	promoteFromWaitlist(game)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Participant removed from the game"})
+}
+
+// LeaveGame lets a participant give up their spot, promoting the next user on the waitlist
+func LeaveGame(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !game.IsOpen() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game is " + game.Status + " and its roster can no longer change"})
+		return
+	}
+
+	// Check if game has already started
+	if game.StartTime.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game has already started"})
+		return
+	}
+
+	err := Repos.Participants.Transition(game.ID, userID.(string), models.ParticipantJoined, models.ParticipantLeft, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not joined this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave game"})
+		return
+	}
+
+	promoteFromWaitlist(game)
+
+	c.JSON(http.StatusOK, gin.H{"message": "You have left the game"})
+}
+
+// GetWaitlistPosition returns the authenticated user's position on a game's waitlist
+func GetWaitlistPosition(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	participant, err := Repos.Participants.Get(game.ID, userID.(string))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && participant.Status != models.ParticipantWaitlisted) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not on the waitlist for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participant"})
+		return
+	}
+
+	position, err := waitlistPosition(game.ID, participant.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WaitlistPositionResponse{
+		GameID:   game.ID,
+		Position: position,
+		JoinedAt: participant.JoinedAt,
+	})
+}
+
+// LeaveWaitlist removes the authenticated user from a game's waitlist
+func LeaveWaitlist(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	err := Repos.Participants.Transition(game.ID, userID.(string), models.ParticipantWaitlisted, models.ParticipantLeft, time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not on the waitlist for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You have left the waitlist"})
+}
+
+// waitlistPosition returns the 1-based position of a user on a game's waitlist, or 0 if they are not on it
+func waitlistPosition(gameID, userID string) (int, error) {
+	participants, err := Repos.Participants.ListByGame(gameID)
+	if err != nil {
+		return 0, err
+	}
+
+	position := 0
+	for _, participant := range participants {
+		if participant.Status != models.ParticipantWaitlisted {
+			continue
+		}
+		position++
+		if participant.UserID == userID {
+			return position, nil
+		}
+	}
+	return 0, nil
+}
+
+// promoteFromWaitlist fills open spots in a game with waitlisted users in the
+// order they joined the waitlist, notifying each promoted user. Failures are
+// logged rather than returned since the spot has already been freed.
+func promoteFromWaitlist(game models.Game) {
+	if !game.IsOpen() {
+		return
+	}
+
+	promoted, err := Repos.Participants.PromoteWaitlisted(game.ID, time.Now())
+	if err != nil {
+		log.Printf("Failed to promote waitlist for game %s: %v", game.ID, err)
+		return
+	}
+
+	for _, participant := range promoted {
+		notifyUser(participant.UserID, fmt.Sprintf("Good news! A spot opened up in %s on %s and you have been moved from the waitlist onto the roster.",
+			game.EventName, game.StartTime.Format("Mon 2 Jan 15:04")))
+	}
+}
+
+// notifyUser sends a text message to a registered user, logging any failure
+func notifyUser(userID, message string) {
+	user, err := Repos.Users.GetByID(userID)
+	if err != nil {
+		log.Printf("Failed to notify user %s: %v", userID, err)
+		return
+	}
+	if user.IsDeleted() {
+		return
+	}
+
+	if err := SMS.SendMessage(user.Phone, message); err != nil {
+		log.Printf("Failed to notify user %s: %v", userID, err)
+	}
+}
+
+// notifyRoster notifies every joined and waitlisted user of a game
+func notifyRoster(gameID, message string) {
+	participants, err := Repos.Participants.ListByGame(gameID)
+	if err != nil {
+		log.Printf("Failed to load roster for game %s: %v", gameID, err)
+		return
+	}
+
+	for _, participant := range participants {
+		if participant.Status == models.ParticipantJoined || participant.Status == models.ParticipantWaitlisted {
+			notifyUser(participant.UserID, message)
+		}
+	}
+}
+
+// newParticipantResponse builds a roster entry, including the participant's
+// public name unless their account has been deleted
+func newParticipantResponse(participant models.Participant) models.ParticipantResponse {
+	response := models.ParticipantResponse{
+		UserID:   participant.UserID,
+		Status:   participant.Status,
+		JoinedAt: participant.JoinedAt,
+	}
+	if user, err := Repos.Users.GetByID(participant.UserID); err == nil && !user.IsDeleted() {
+		response.DisplayName = user.PublicName()
+	}
+	return response
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// phoneChangeKey is the OTP key of a user's pending phone change. Keying it by
+// user rather than number ties the code to the account that asked for it.
+func phoneChangeKey(userID string) string {
+	return "phone-change:" + userID
+}
+
+// RequestPhoneChange texts a code to the number the signed-in user wants to
+// switch to. Numbers registered to another account are refused.
+func RequestPhoneChange(c *gin.Context) {
+	var req models.PhoneChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	phone, err := utils.NormalizePhone(req.PhoneNumber)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+	if phone == user.Phone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This is already your phone number"})
+		return
+	}
+	if !checkPhoneAvailable(c, phone) {
+		return
+	}
+
+	if !sendOTP(c, phoneChangeKey(user.ID), phone, phone) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OTP sent to the new phone number"})
+}
+
+// VerifyPhoneChange moves the signed-in user to the new number once the code
+// sent to it is verified. Every session is signed out, since access tokens
+// carry the old number, and a new one is started for this device. The old
+// number is told about the change.
+func VerifyPhoneChange(c *gin.Context) {
+	var req models.PhoneChangeVerify
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+
+	data, ok := verifyOTP(c, phoneChangeKey(user.ID), req.OTP)
+	if !ok {
+		return
+	}
+
+	// The number is unique, so this fails if another account took it meanwhile
+	oldPhone := user.Phone
+	now := time.Now()
+	user.Phone = data.Target
+	user.UpdatedAt = now
+	if err := Repos.Users.Update(user); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This phone number is registered to another account"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update phone number"})
+		return
+	}
+
+	if err := Repos.OTPs.Delete(phoneChangeKey(user.ID)); err != nil {
+		log.Printf("Failed to clear phone change OTP of user %s: %v", user.ID, err)
+	}
+	if err := Repos.Sessions.RevokeAll(user.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	token, refreshToken, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	message := fmt.Sprintf("The phone number of your account was changed to one ending in %s. If this wasn't you, contact support.",
+		data.Target[len(data.Target)-4:])
+	if err := SMS.SendMessage(oldPhone, message); err != nil {
+		log.Printf("Failed to notify %s of phone change: %v", oldPhone, err)
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(gin.H{
+		"message": "Phone number changed",
+		"user":    newUserResponse(user),
+	}, token, refreshToken))
+}
+
+// checkPhoneAvailable writes a conflict response if a number is registered
+func checkPhoneAvailable(c *gin.Context, phone string) bool {
+	_, err := Repos.Users.GetByPhone(phone)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This phone number is registered to another account"})
+		return false
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check phone number"})
+		return false
+	}
+	return true
+}
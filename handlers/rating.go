@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// Page size limits of a user's rating history
+const (
+	defaultRatingHistorySize = 20
+	maxRatingHistorySize     = 100
+)
+
+// provisionalDeviation is the rating deviation above which a rating is shown
+// as provisional. A new player needs a handful of rated games to get below it.
+const provisionalDeviation = 110
+
+// SubmitGameResult lets the creator of a completed game, or the admins of the
+// club that owns it, record which team won.
+// The teams default to the ones last picked with SplitTeams. The rating of
+// every player is updated from the result, once per game.
+func SubmitGameResult(c *gin.Context) {
+	// The body is optional: an empty one records a draw between the picked teams
+	var req models.GameResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, game, "submit its result") {
+		return
+	}
+	if game.Status != models.GameCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Results can only be submitted for completed games"})
+		return
+	}
+
+	if req.Teams == nil {
+		split, err := Repos.Games.GetTeams(game.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "teams are required as no teams were picked for this game"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load teams"})
+			return
+		}
+		req.Teams = split.Teams
+	}
+	if !validateResultTeams(c, game.ID, req.Teams) {
+		return
+	}
+	winner := -1
+	if req.Winner != nil {
+		if *req.Winner < 0 || *req.Winner >= len(req.Teams) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("winner must be a team index from 0 to %d, or omitted for a draw", len(req.Teams)-1)})
+			return
+		}
+		winner = *req.Winner
+	}
+
+	result := models.GameResult{
+		GameID:      game.ID,
+		Teams:       req.Teams,
+		Winner:      winner,
+		SubmittedBy: c.GetString("userID"),
+		SubmittedAt: time.Now(),
+	}
+	changes, err := Repos.Ratings.RecordResult(result, func(current map[string]models.Rating) []models.RatingChange {
+		return rateGame(result, current)
+	})
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A result has already been submitted for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save result"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.GameResultResponse{GameResult: result, RatingChanges: changes})
+}
+
+// validateResultTeams checks that a result has at least two teams and that
+// every player is on exactly one of them and on the game's roster
+func validateResultTeams(c *gin.Context, gameID string, teams [][]string) bool {
+	if len(teams) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A result needs at least two teams"})
+		return false
+	}
+
+	participants, err := Repos.Participants.ListByGame(gameID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participants"})
+		return false
+	}
+	joined := make(map[string]bool)
+	for _, participant := range participants {
+		if participant.Status == models.ParticipantJoined {
+			joined[participant.UserID] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, team := range teams {
+		if len(team) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Team %d has no players", i)})
+			return false
+		}
+		for _, userID := range team {
+			if seen[userID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "User " + userID + " is on more than one team"})
+				return false
+			}
+			if !joined[userID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "User " + userID + " is not on the game's roster"})
+				return false
+			}
+			seen[userID] = true
+		}
+	}
+	return true
+}
+
+// rateGame computes every player's new rating from a result. Each team plays
+// against the combined rating of every other team, and all players are rated
+// from the ratings they had before the game.
+func rateGame(result models.GameResult, current map[string]models.Rating) []models.RatingChange {
+	ratings := make([][]utils.GlickoRating, len(result.Teams))
+	combined := make([]utils.GlickoRating, len(result.Teams))
+	for i, team := range result.Teams {
+		for _, userID := range team {
+			ratings[i] = append(ratings[i], glickoRating(current, userID))
+		}
+		combined[i] = utils.TeamGlickoRating(ratings[i])
+	}
+
+	var changes []models.RatingChange
+	for i, team := range result.Teams {
+		var results []utils.GlickoResult
+		var total float64
+		for j := range result.Teams {
+			if j == i {
+				continue
+			}
+			score := 0.5
+			if result.Winner == i {
+				score = 1
+			} else if result.Winner == j {
+				score = 0
+			}
+			results = append(results, utils.GlickoResult{Opponent: combined[j], Score: score})
+			total += score
+		}
+
+		for k, userID := range team {
+			before := ratings[i][k]
+			after := utils.UpdateGlicko(before, results)
+			changes = append(changes, models.RatingChange{
+				UserID:          userID,
+				GameID:          result.GameID,
+				Score:           total / float64(len(results)),
+				RatingBefore:    before.Rating,
+				RatingAfter:     after.Rating,
+				DeviationBefore: before.Deviation,
+				DeviationAfter:  after.Deviation,
+				VolatilityAfter: after.Volatility,
+				ChangedAt:       result.SubmittedAt,
+			})
+		}
+	}
+	return changes
+}
+
+// glickoRating returns a user's stored rating, or the starting rating if they
+// have never been rated
+func glickoRating(ratings map[string]models.Rating, userID string) utils.GlickoRating {
+	rating, exists := ratings[userID]
+	if !exists {
+		return utils.NewGlickoRating()
+	}
+	return utils.GlickoRating{Rating: rating.Rating, Deviation: rating.Deviation, Volatility: rating.Volatility}
+}
+
+// GetGameResult returns a game's result and the rating changes it caused
+func GetGameResult(c *gin.Context) {
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	result, err := Repos.Ratings.GetResult(game.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No result has been submitted for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load result"})
+		return
+	}
+
+	changes, err := Repos.Ratings.ListByGame(game.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating changes"})
+		return
+	}
+	if changes == nil {
+		changes = []models.RatingChange{}
+	}
+
+	c.JSON(http.StatusOK, models.GameResultResponse{GameResult: result, RatingChanges: changes})
+}
+
+// GetRatingHistory returns a user's rating and their most recent rating
+// changes. Query parameters:
+//
+//	limit      number of changes, 1 to 100 (default 20)
+func GetRatingHistory(c *gin.Context) {
+	limit := defaultRatingHistorySize
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxRatingHistorySize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxRatingHistorySize)})
+			return
+		}
+	}
+
+	user, err := Repos.Users.GetByID(c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && user.IsDeleted()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	rating, ok := loadRating(c, user.ID)
+	if !ok {
+		return
+	}
+	changes, err := Repos.Ratings.History(user.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating history"})
+		return
+	}
+	if changes == nil {
+		changes = []models.RatingChange{}
+	}
+
+	c.JSON(http.StatusOK, models.RatingHistoryResponse{
+		UserID:  user.ID,
+		Rating:  rating,
+		Changes: changes,
+	})
+}
+
+// loadRating returns a user's rating as shown on profiles, writing an error
+// response if it cannot be loaded. Users who were never rated get the
+// starting rating.
+func loadRating(c *gin.Context, userID string) (models.RatingResponse, bool) {
+	rating, err := Repos.Ratings.Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		rating = models.Rating{UserID: userID, Rating: utils.DefaultRating, Deviation: utils.DefaultDeviation, Volatility: utils.DefaultVolatility}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load rating"})
+		return models.RatingResponse{}, false
+	}
+
+	return models.RatingResponse{
+		Rating:      int(math.Round(rating.Rating)),
+		Deviation:   int(math.Round(rating.Deviation)),
+		GamesRated:  rating.GamesRated,
+		Provisional: rating.Deviation > provisionalDeviation,
+	}, true
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// seriesHorizon is how far ahead concrete games are generated for a series
+const seriesHorizon = 28 * 24 * time.Hour
+
+// CreateSeries creates a recurring game series and generates its games for the
+// rolling horizon. Single occurrences are edited and cancelled through the
+// regular game endpoints.
+func CreateSeries(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.SeriesCreationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The first occurrence follows the same rules as a single game
+	startTime, endTime, ok := parseGameTimes(c, req.StartTime, req.EndTime)
+	if !ok {
+		return
+	}
+
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = startTime.Format("-07:00")
+	}
+	loc, err := loadSeriesLocation(timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone " + req.Timezone})
+		return
+	}
+
+	if !validateCoordinates(c, req.Coordinates) {
+		return
+	}
+	if !validateGameCapacity(c, req.CostPerPerson, req.PlayerRequirement) {
+		return
+	}
+
+	if !validateGameClub(c, req.ClubID, req.MembersOnly, "create series for this club") {
+		return
+	}
+
+	if _, err := utils.ParseRRule(req.RRule, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurrence rule: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	series := models.GameSeries{
+		ID:                uuid.New().String(),
+		CreatorID:         userID.(string),
+		RRule:             strings.TrimPrefix(req.RRule, "RRULE:"),
+		Timezone:          timezone,
+		EventName:         req.EventName,
+		Location:          req.Location,
+		Coordinates:       req.Coordinates,
+		CostPerPerson:     req.CostPerPerson,
+		PlayerRequirement: req.PlayerRequirement,
+		ClubID:            req.ClubID,
+		MembersOnly:       req.MembersOnly,
+		StartTime:         startTime.In(loc),
+		EndTime:           endTime.In(loc),
+		Status:            models.SeriesActive,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	if err := Repos.Series.Create(series); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create series"})
+		return
+	}
+
+	series, err = generateSeriesGames(series, now.Add(seriesHorizon))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate series games"})
+		return
+	}
+
+	respondWithSeries(c, http.StatusCreated, series)
+}
+
+// GetSeries returns a series and the games generated for it
+func GetSeries(c *gin.Context) {
+	series, ok := loadSeries(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	respondWithSeries(c, http.StatusOK, series)
+}
+
+// UpdateSeries edits every upcoming occurrence of a series, starting from the
+// occurrence given in the from query parameter or from now if it is omitted.
+// The series template is updated too, so games generated later match.
+func UpdateSeries(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GameUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.StartTime != nil || req.EndTime != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Times cannot be changed for a whole series. Edit single games, or cancel the remaining occurrences and create a new series"})
+		return
+	}
+
+	series, ok := loadSeries(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, seriesTemplate(series), "edit this series") {
+		return
+	}
+
+	if series.Status != models.SeriesActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Series is " + series.Status + " and can no longer be edited"})
+		return
+	}
+
+	// Validate the edit against the template before touching any games
+	template, ok := applyGameUpdate(c, seriesTemplate(series), req)
+	if !ok {
+		return
+	}
+
+	targets, _, ok := upcomingOccurrences(c, series, c.Query("from"))
+	if !ok {
+		return
+	}
+
+	// Check every occurrence first so the series is not left half edited
+	updates := make([]models.Game, len(targets))
+	for i, game := range targets {
+		if updates[i], ok = applyGameUpdate(c, game, req); !ok {
+			return
+		}
+	}
+
+	var updatedGames []models.GameResponse
+	for i, game := range targets {
+		updated, _, err := saveGameUpdate(game, updates[i], userID.(string))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update game " + game.ID})
+			return
+		}
+		updatedGames = append(updatedGames, newGameResponse(updated))
+	}
+
+	series.EventName = template.EventName
+	series.Location = template.Location
+	series.Coordinates = template.Coordinates
+	series.CostPerPerson = template.CostPerPerson
+	series.PlayerRequirement = template.PlayerRequirement
+	series.MembersOnly = template.MembersOnly
+	series.UpdatedAt = time.Now()
+	if err := Repos.Series.Update(series); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Series updated",
+		"series":  series,
+		"games":   updatedGames,
+	})
+}
+
+// CancelSeries cancels a series from the given occurrence onwards, or every
+// upcoming occurrence if none is given. No further games are generated past
+// the cancellation point.
+func CancelSeries(c *gin.Context) {
+	var req models.SeriesCancelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A cancellation reason is required"})
+		return
+	}
+
+	series, ok := loadSeries(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, seriesTemplate(series), "cancel this series") {
+		return
+	}
+
+	if series.Status != models.SeriesActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Series is already " + series.Status})
+		return
+	}
+
+	targets, cutoff, ok := upcomingOccurrences(c, series, req.FromGameID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	var cancelled []models.GameResponse
+	for _, game := range targets {
+		game.Status = models.GameCancelled
+		game.CancelReason = req.Reason
+		game.Sequence++
+		game.UpdatedAt = now
+		if err := Repos.Games.Update(game); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel game " + game.ID})
+			return
+		}
+
+		notifyRoster(game.ID, fmt.Sprintf("%s on %s has been cancelled: %s",
+			game.EventName, game.StartTime.Format("Mon 2 Jan 15:04"), req.Reason))
+		cancelled = append(cancelled, newGameResponse(game))
+	}
+
+	// Cancelling from the first occurrence or from now ends the whole series
+	series.EndsBefore = cutoff
+	if req.FromGameID == "" || !cutoff.After(series.StartTime) {
+		series.Status = models.SeriesCancelled
+	}
+	series.UpdatedAt = now
+	if err := Repos.Series.Update(series); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Series cancelled",
+		"series":  series,
+		"games":   cancelled,
+	})
+}
+
+// StartSeriesGeneration periodically generates games for active series so
+// that each always has games scheduled for the rolling horizon
+func StartSeriesGeneration(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			active, err := Repos.Series.ListActive()
+			if err != nil {
+				log.Printf("Failed to list active series: %v", err)
+			}
+			for _, series := range active {
+				if _, err := generateSeriesGames(series, time.Now().Add(seriesHorizon)); err != nil {
+					log.Printf("Failed to generate games for series %s: %v", series.ID, err)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// generateSeriesGames creates games for the occurrences of a series that start
+// before until and have not been generated yet, then records how far the
+// series has been generated. Occurrences in the past are skipped.
+func generateSeriesGames(series models.GameSeries, until time.Time) (models.GameSeries, error) {
+	loc, err := loadSeriesLocation(series.Timezone)
+	if err != nil {
+		return series, err
+	}
+	rule, err := utils.ParseRRule(series.RRule, loc)
+	if err != nil {
+		return series, err
+	}
+
+	before := until
+	if !series.EndsBefore.IsZero() && series.EndsBefore.Before(before) {
+		before = series.EndsBefore
+	}
+
+	now := time.Now()
+	duration := series.EndTime.Sub(series.StartTime)
+	generatedUntil := series.GeneratedUntil
+	var created []models.Game
+	for _, start := range rule.Between(series.StartTime.In(loc), series.GeneratedUntil, before) {
+		if start.Before(now) {
+			continue
+		}
+
+		game := models.Game{
+			ID:                uuid.New().String(),
+			EventName:         series.EventName,
+			StartTime:         start,
+			EndTime:           start.Add(duration),
+			Location:          series.Location,
+			Coordinates:       series.Coordinates,
+			CostPerPerson:     series.CostPerPerson,
+			PlayerRequirement: series.PlayerRequirement,
+			CreatorID:         series.CreatorID,
+			ClubID:            series.ClubID,
+			MembersOnly:       series.MembersOnly,
+			Status:            models.GameScheduled,
+			SeriesID:          series.ID,
+			RecurrenceID:      start,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		}
+		if err = Repos.Games.Create(game); err != nil {
+			break
+		}
+		created = append(created, game)
+		generatedUntil = start.Add(time.Nanosecond)
+	}
+	if err == nil && until.After(generatedUntil) {
+		generatedUntil = until
+	}
+
+	// Record progress even after a failure so created games are not duplicated
+	if !generatedUntil.After(series.GeneratedUntil) {
+		return series, err
+	}
+	stored, advanceErr := Repos.Series.AdvanceGenerated(series.ID, generatedUntil, now)
+	if advanceErr != nil {
+		if err == nil {
+			err = advanceErr
+		}
+		return series, err
+	}
+
+	// The series may have been cancelled or cut short while its games were
+	// generated, too late for the cancellation to see them
+	for _, game := range created {
+		if stored.Status == models.SeriesActive && (stored.EndsBefore.IsZero() || game.StartTime.Before(stored.EndsBefore)) {
+			continue
+		}
+		if cancelErr := cancelGeneratedGame(game.ID, now); cancelErr != nil && err == nil {
+			err = cancelErr
+		}
+	}
+	return stored, err
+}
+
+// cancelGeneratedGame cancels a game generated for a series that has since
+// been cancelled past its start
+func cancelGeneratedGame(gameID string, at time.Time) error {
+	game, err := Repos.Games.Get(gameID)
+	if err != nil {
+		return err
+	}
+	if !game.IsOpen() {
+		return nil
+	}
+
+	game.Status = models.GameCancelled
+	game.CancelReason = "The series has been cancelled"
+	game.Sequence++
+	game.UpdatedAt = at
+	if err := Repos.Games.Update(game); err != nil {
+		return err
+	}
+	notifyRoster(game.ID, fmt.Sprintf("%s on %s has been cancelled: %s",
+		game.EventName, game.StartTime.Format("Mon 2 Jan 15:04"), game.CancelReason))
+	return nil
+}
+
+// upcomingOccurrences returns the open games of a series that have not started,
+// starting from the occurrence fromGameID or from now if it is empty, along
+// with that cutoff time. It writes an error response if fromGameID is not part
+// of the series.
+func upcomingOccurrences(c *gin.Context, series models.GameSeries, fromGameID string) ([]models.Game, time.Time, bool) {
+	cutoff := time.Now()
+	if fromGameID != "" {
+		from, err := Repos.Games.Get(fromGameID)
+		if (err == nil && from.SeriesID != series.ID) || errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Game " + fromGameID + " is not part of this series"})
+			return nil, cutoff, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load game"})
+			return nil, cutoff, false
+		}
+		cutoff = from.RecurrenceID
+	}
+
+	games, err := Repos.Games.ListBySeries(series.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series games"})
+		return nil, cutoff, false
+	}
+
+	now := time.Now()
+	var upcoming []models.Game
+	for _, game := range games {
+		if game.IsOpen() && !game.RecurrenceID.Before(cutoff) && game.StartTime.After(now) {
+			upcoming = append(upcoming, game)
+		}
+	}
+	return upcoming, cutoff, true
+}
+
+// loadSeries fetches a series by ID, writing a not found or server error response if it cannot be loaded.
+// Members-only series are not found for users outside their club.
+func loadSeries(c *gin.Context, seriesID string) (models.GameSeries, bool) {
+	series, err := Repos.Series.Get(seriesID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+		return models.GameSeries{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series"})
+		return models.GameSeries{}, false
+	}
+	if viewer := gameViewer(c); viewer != "" {
+		visible, err := canSeeGame(seriesTemplate(series), viewer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series"})
+			return models.GameSeries{}, false
+		}
+		if !visible {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Series not found"})
+			return models.GameSeries{}, false
+		}
+	}
+	return series, true
+}
+
+// respondWithSeries writes a series together with its generated games
+func respondWithSeries(c *gin.Context, status int, series models.GameSeries) {
+	games, err := Repos.Games.ListBySeries(series.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load series games"})
+		return
+	}
+
+	gameList := []models.GameResponse{}
+	for _, game := range games {
+		gameList = append(gameList, newGameResponse(game))
+	}
+
+	c.JSON(status, models.SeriesResponse{
+		Series: series,
+		Games:  gameList,
+	})
+}
+
+// seriesTemplate returns the series template as a game so it can be validated like one
+func seriesTemplate(series models.GameSeries) models.Game {
+	return models.Game{
+		EventName:         series.EventName,
+		StartTime:         series.StartTime,
+		EndTime:           series.EndTime,
+		Location:          series.Location,
+		Coordinates:       series.Coordinates,
+		CostPerPerson:     series.CostPerPerson,
+		PlayerRequirement: series.PlayerRequirement,
+		CreatorID:         series.CreatorID,
+		ClubID:            series.ClubID,
+		MembersOnly:       series.MembersOnly,
+	}
+}
+
+// loadSeriesLocation resolves a series timezone, which is either an IANA name
+// or a fixed UTC offset such as +02:00
+func loadSeriesLocation(timezone string) (*time.Location, error) {
+	if strings.HasPrefix(timezone, "+") || strings.HasPrefix(timezone, "-") {
+		offset, err := time.Parse("-07:00", timezone)
+		if err != nil {
+			return nil, err
+		}
+		return offset.Location(), nil
+	}
+	return time.LoadLocation(timezone)
+}
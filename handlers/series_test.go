@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+)
+
+// asUser serves a request signed in as a player and returns the response
+func asUser(t *testing.T, r *gin.Engine, userID, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", userID)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestClubSeriesAreManagedByClubAdmins(t *testing.T) {
+	repos := useMemoryRepos(t)
+	now := time.Now()
+	for i, id := range []string{"owner", "ana", "ben"} {
+		user := models.User{ID: id, FirstName: id, Phone: "+1415555020" + string(rune('0'+i)), Role: models.RolePlayer, CreatedAt: now, UpdatedAt: now}
+		if err := repos.Users.Create(user); err != nil {
+			t.Fatalf("create user %s: %v", id, err)
+		}
+	}
+	club := models.Club{ID: "club", Name: "Sunday Crew", CreatedAt: now, UpdatedAt: now}
+	if err := repos.Clubs.Create(club, models.ClubMember{ClubID: "club", UserID: "owner", Role: models.ClubRoleOwner, JoinedAt: now}); err != nil {
+		t.Fatalf("create club: %v", err)
+	}
+	if err := repos.Clubs.RequestToJoin(models.ClubJoinRequest{ClubID: "club", UserID: "ana", Status: models.JoinRequestPending, CreatedAt: now}); err != nil {
+		t.Fatalf("request to join: %v", err)
+	}
+	if err := repos.Clubs.DecideJoinRequest(models.ClubJoinRequest{ClubID: "club", UserID: "ana", Status: models.JoinRequestApproved, CreatedAt: now, DecidedBy: "owner", DecidedAt: now}); err != nil {
+		t.Fatalf("approve join request: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-User"))
+		c.Set("role", models.RolePlayer)
+	})
+	r.POST("/series/create", CreateSeries)
+	r.GET("/series/:id", GetSeries)
+	r.POST("/series/:id/cancel", CancelSeries)
+
+	start := now.Add(24 * time.Hour).UTC().Truncate(time.Second)
+	create := map[string]any{
+		"event_name":         "Club 5s",
+		"start_time":         start.Format(time.RFC3339),
+		"end_time":           start.Add(time.Hour).Format(time.RFC3339),
+		"location":           "Court 1",
+		"cost_per_person":    5,
+		"player_requirement": 10,
+		"club_id":            "club",
+		"members_only":       true,
+		"rrule":              "FREQ=WEEKLY;COUNT=3",
+		"timezone":           "UTC",
+	}
+	if w := asUser(t, r, "ana", http.MethodPost, "/series/create", create); w.Code != http.StatusForbidden {
+		t.Fatalf("series created by a plain member: status %d: %s", w.Code, w.Body.String())
+	}
+	w := asUser(t, r, "owner", http.MethodPost, "/series/create", create)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create series: status %d: %s", w.Code, w.Body.String())
+	}
+	var created models.SeriesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode series: %v", err)
+	}
+	if created.Series.ClubID != "club" || !created.Series.MembersOnly || len(created.Games) != 3 {
+		t.Fatalf("series %+v with %d games, want a members-only club series with 3 games", created.Series, len(created.Games))
+	}
+	for _, game := range created.Games {
+		stored, err := repos.Games.Get(game.ID)
+		if err != nil {
+			t.Fatalf("get game %s: %v", game.ID, err)
+		}
+		if stored.ClubID != "club" || !stored.MembersOnly {
+			t.Errorf("generated game %s has club %q, members only %t", stored.ID, stored.ClubID, stored.MembersOnly)
+		}
+	}
+
+	path := "/series/" + created.Series.ID
+	if w := asUser(t, r, "ben", http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("members-only series shown to a non-member: status %d", w.Code)
+	}
+	if w := asUser(t, r, "ana", http.MethodGet, path, nil); w.Code != http.StatusOK {
+		t.Errorf("members-only series hidden from a member: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := asUser(t, r, "ana", http.MethodPost, path+"/cancel", map[string]string{"reason": "Pitch closed"}); w.Code != http.StatusForbidden {
+		t.Errorf("series cancelled by a plain member: status %d: %s", w.Code, w.Body.String())
+	}
+
+	// Club admins manage the series, not just its creator
+	promoted := models.ClubMember{ClubID: "club", UserID: "ana", Role: models.ClubRoleAdmin, JoinedAt: now}
+	if err := repos.Clubs.UpdateMembers([]models.ClubMember{promoted}); err != nil {
+		t.Fatalf("promote member: %v", err)
+	}
+	if w := asUser(t, r, "ana", http.MethodPost, path+"/cancel", map[string]string{"reason": "Pitch closed"}); w.Code != http.StatusOK {
+		t.Errorf("series cancelled by a club admin: status %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// refreshTokenTTL is how long a session lasts without being refreshed
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// issueSession starts a session for a user on the requesting device and
+// returns its access token and refresh token
+func issueSession(c *gin.Context, user models.User) (string, string, error) {
+	secret, err := utils.GenerateToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		TokenHash:  utils.HashToken(secret),
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+	if err := Repos.Sessions.Create(session); err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := utils.GenerateJWT(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, session.ID + "." + secret, nil
+}
+
+// tokenResponse adds a freshly issued token pair to a response
+func tokenResponse(response gin.H, accessToken, refreshToken string) gin.H {
+	response["token"] = accessToken
+	response["refresh_token"] = refreshToken
+	response["expires_in"] = int(utils.AccessTokenTTL.Seconds())
+	return response
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once; presenting one that has
+// already been rotated out revokes the whole session, since it means the
+// token was copied.
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sessionID, secret, found := strings.Cut(req.RefreshToken, ".")
+	if !found {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	session, err := Repos.Sessions.Get(sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load session"})
+		return
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+		return
+	}
+
+	tokenHash := utils.HashToken(secret)
+	if session.PreviousTokenHash != "" && tokenHash == session.PreviousTokenHash {
+		if err := Repos.Sessions.Revoke(session.ID, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used, the session has been revoked"})
+		return
+	}
+
+	// Suspended and deleted users keep no sessions, so theirs is revoked
+	// rather than rotated
+	user, err := Repos.Users.GetByID(session.UserID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	if err != nil || user.IsDeleted() || user.IsSuspended() {
+		if err := Repos.Sessions.Revoke(session.ID, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+		if err != nil || user.IsDeleted() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended", "reason": user.SuspendedReason})
+		return
+	}
+
+	newSecret, err := utils.GenerateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	err = Repos.Sessions.Rotate(session.ID, tokenHash, utils.HashToken(newSecret), now.Add(refreshTokenTTL), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	accessToken, err := utils.GenerateJWT(user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(gin.H{}, accessToken, session.ID+"."+newSecret))
+}
+
+// Logout revokes the session of the access token used for the request
+func Logout(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token does not belong to a session"})
+		return
+	}
+
+	if err := Repos.Sessions.Revoke(sessionID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// ListSessions returns the authenticated user's active sessions, most
+// recently used first
+func ListSessions(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions, err := Repos.Sessions.ListByUser(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	now := time.Now()
+	sessionList := []models.SessionResponse{}
+	for _, session := range sessions {
+		if !session.IsActive(now) {
+			continue
+		}
+		sessionList = append(sessionList, models.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == c.GetString("sessionID"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessionList})
+}
+
+// RevokeSession logs one of the authenticated user's devices out
+func RevokeSession(c *gin.Context) {
+	// Get user ID from JWT claims
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, err := Repos.Sessions.Get(c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && session.UserID != userID.(string)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load session"})
+		return
+	}
+
+	if err := Repos.Sessions.Revoke(session.ID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// JWKS publishes the public keys that verify access tokens so other services
+// can check rondo tokens themselves
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.Keys.JWKS())
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+	"rondo/repository"
+	"rondo/utils"
+)
+
+// maxTeams is the largest number of teams a roster can be split into
+const maxTeams = 8
+
+// SplitTeams lets the creator of a game or its club's admins divide its joined players into teams
+// of even total rating. Players of the same preferred position are spread
+// across the teams, and keep-together and keep-apart groups are honoured.
+// Calling it again reshuffles the teams.
+func SplitTeams(c *gin.Context) {
+	// The body is optional: an empty one reshuffles with the previous settings
+	var req models.TeamSplitRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if !requireGameManager(c, game, "pick teams") {
+		return
+	}
+	if !game.IsOpen() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Teams can only be picked for scheduled or confirmed games"})
+		return
+	}
+
+	previous, err := Repos.Games.GetTeams(game.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load teams"})
+		return
+	}
+
+	participants, err := Repos.Participants.ListByGame(game.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load participants"})
+		return
+	}
+	var playerIDs []string
+	joined := make(map[string]bool)
+	for _, participant := range participants {
+		if participant.Status == models.ParticipantJoined {
+			playerIDs = append(playerIDs, participant.UserID)
+			joined[participant.UserID] = true
+		}
+	}
+
+	count := req.Count
+	if count == 0 {
+		count = len(previous.Teams)
+	}
+	if count == 0 {
+		count = 2
+	}
+	if count < 2 || count > maxTeams {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 2 and %d", maxTeams)})
+		return
+	}
+	if count > len(playerIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d joined players cannot be split into %d teams", len(playerIDs), count)})
+		return
+	}
+
+	// Previous groups are reused without the players who have since left
+	keepTogether, keepApart := req.KeepTogether, req.KeepApart
+	if keepTogether == nil {
+		keepTogether = rosterGroups(previous.KeepTogether, joined)
+	} else if !validateTeamGroups(c, "keep_together", keepTogether, joined) {
+		return
+	}
+	if keepApart == nil {
+		keepApart = rosterGroups(previous.KeepApart, joined)
+	} else if !validateTeamGroups(c, "keep_apart", keepApart, joined) {
+		return
+	}
+
+	users, ratings, ok := loadTeamPlayers(c, playerIDs)
+	if !ok {
+		return
+	}
+	var players []utils.TeamPlayer
+	for _, userID := range playerIDs {
+		players = append(players, utils.TeamPlayer{
+			ID:       userID,
+			Rating:   glickoRating(ratings, userID).Rating,
+			Position: users[userID].Position,
+		})
+	}
+
+	teams, err := utils.BalanceTeams(players, count, keepTogether, keepApart, rand.New(rand.NewSource(time.Now().UnixNano())))
+	if errors.Is(err, utils.ErrTeamConstraints) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The players cannot be split into %d teams with these constraints", count)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pick teams"})
+		return
+	}
+
+	split := models.TeamSplit{
+		GameID:       game.ID,
+		Teams:        make([][]string, len(teams)),
+		KeepTogether: keepTogether,
+		KeepApart:    keepApart,
+		CreatedBy:    c.GetString("userID"),
+		CreatedAt:    time.Now(),
+	}
+	for i, team := range teams {
+		for _, player := range team {
+			split.Teams[i] = append(split.Teams[i], player.ID)
+		}
+	}
+	if err := Repos.Games.SaveTeams(split); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save teams"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTeamSplitResponse(split, users, ratings))
+}
+
+// GetTeams returns the teams last picked for a game
+func GetTeams(c *gin.Context) {
+	game, ok := loadGame(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	split, err := Repos.Games.GetTeams(game.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No teams have been picked for this game"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load teams"})
+		return
+	}
+
+	var playerIDs []string
+	for _, team := range split.Teams {
+		playerIDs = append(playerIDs, team...)
+	}
+	users, ratings, ok := loadTeamPlayers(c, playerIDs)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newTeamSplitResponse(split, users, ratings))
+}
+
+// validateTeamGroups checks that every keep-together or keep-apart group has
+// at least two players, all of them on the roster
+func validateTeamGroups(c *gin.Context, field string, groups [][]string, joined map[string]bool) bool {
+	for _, group := range groups {
+		if len(group) < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": field + " groups need at least two players"})
+			return false
+		}
+		for _, userID := range group {
+			if !joined[userID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "User " + userID + " is not on the game's roster"})
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// rosterGroups drops the players who are no longer on the roster from
+// constraint groups, and the groups that are left with a single player
+func rosterGroups(groups [][]string, joined map[string]bool) [][]string {
+	var kept [][]string
+	for _, group := range groups {
+		var players []string
+		for _, userID := range group {
+			if joined[userID] {
+				players = append(players, userID)
+			}
+		}
+		if len(players) >= 2 {
+			kept = append(kept, players)
+		}
+	}
+	return kept
+}
+
+// loadTeamPlayers loads the users and ratings of the given players, writing
+// an error response if they cannot be loaded
+func loadTeamPlayers(c *gin.Context, userIDs []string) (map[string]models.User, map[string]models.Rating, bool) {
+	users := make(map[string]models.User, len(userIDs))
+	for _, userID := range userIDs {
+		user, err := Repos.Users.GetByID(userID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load players"})
+			return nil, nil, false
+		}
+		users[userID] = user
+	}
+
+	ratings, err := Repos.Ratings.ListByUsers(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load ratings"})
+		return nil, nil, false
+	}
+	return users, ratings, true
+}
+
+// newTeamSplitResponse builds the representation of a team split with the
+// players' names and current ratings
+func newTeamSplitResponse(split models.TeamSplit, users map[string]models.User, ratings map[string]models.Rating) models.TeamSplitResponse {
+	response := models.TeamSplitResponse{
+		GameID:       split.GameID,
+		Teams:        []models.TeamResponse{},
+		KeepTogether: split.KeepTogether,
+		KeepApart:    split.KeepApart,
+		CreatedAt:    split.CreatedAt,
+	}
+	if response.KeepTogether == nil {
+		response.KeepTogether = [][]string{}
+	}
+	if response.KeepApart == nil {
+		response.KeepApart = [][]string{}
+	}
+
+	for _, team := range split.Teams {
+		teamResponse := models.TeamResponse{Players: []models.TeamPlayerResponse{}}
+		var total float64
+		for _, userID := range team {
+			rating := glickoRating(ratings, userID).Rating
+			total += rating
+			teamResponse.Players = append(teamResponse.Players, models.TeamPlayerResponse{
+				UserID:      userID,
+				DisplayName: users[userID].PublicName(),
+				Position:    users[userID].Position,
+				Rating:      int(math.Round(rating)),
+			})
+		}
+		teamResponse.TotalRating = int(math.Round(total))
+		response.Teams = append(response.Teams, teamResponse)
+	}
+	return response
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	
 	"rondo/models"
+	"rondo/repository"
 	"rondo/utils"
 )
 
@@ -38,57 +43,210 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 	
-	// Check if user already exists
-	_, userExists := utils.GetUserByPhone(phoneNumber)
-	if userExists {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with this phone number already exists"})
-		return
-	}
-	
 	// Create user
-	user, err := utils.CreateUser(req, phoneNumber)
+	user, err := utils.NewUser(req, phoneNumber)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user)
+	// Store user, rejecting phone numbers that are already registered
+	if err := Repos.Users.Create(user); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User with this phone number already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	
+	// Redeem the registration token only once the user exists, so a failed
+	// registration can be retried with it. The phone number's uniqueness
+	// already rejects a second registration; the token record stops one
+	// after the account is deleted, and the new user is removed again.
+	if err := Repos.UsedTokens.Use(c.GetString("tokenID"), c.GetTime("tokenExpiresAt"), time.Now()); err != nil {
+		if deleteErr := Repos.Users.Delete(user.ID); deleteErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo registration"})
+			return
+		}
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Registration token has already been used"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem registration token"})
+		return
+	}
+	
+	// Start a session for the new user
+	token, refreshToken, err := issueSession(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
 		return
 	}
 
-	// Return user data with token
-	c.JSON(http.StatusCreated, gin.H{
-		"user": models.UserResponse{
-			ID:        user.ID,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			DOB:       user.DOB,
-			Phone:     user.Phone,
-			CreatedAt: user.CreatedAt,
-		},
-		"token": token,
-	})
+	// Return user data with tokens
+	c.JSON(http.StatusCreated, tokenResponse(gin.H{
+		"user": newUserResponse(user),
+	}, token, refreshToken))
 }
 
-// GetUserProfile retrieves user profile by phone number
-func GetUserProfile(c *gin.Context) {
-	phone := c.Param("phone")
+// Limits on profile names
+const maxNameLength = 50
+
+// GetMyProfile returns the signed-in user's own profile
+func GetMyProfile(c *gin.Context) {
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
 	
-	user, exists := utils.GetUserByPhone(phone)
-	if !exists {
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// UpdateMyProfile changes the signed-in user's names and what their public
+// profile shows
+func UpdateMyProfile(c *gin.Context) {
+	var req models.ProfileUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	
+	user, ok := loadUser(c, c.GetString("userID"))
+	if !ok {
+		return
+	}
+	
+	// Names are required, the display name may be cleared
+	names := []struct {
+		field    string
+		value    *string
+		target   *string
+		required bool
+	}{
+		{"first_name", req.FirstName, &user.FirstName, true},
+		{"last_name", req.LastName, &user.LastName, true},
+		{"display_name", req.DisplayName, &user.DisplayName, false},
+	}
+	for _, name := range names {
+		if name.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(*name.value)
+		if name.required && value == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name.field + " cannot be empty"})
+			return
+		}
+		if utf8.RuneCountInString(value) > maxNameLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be longer than %d characters", name.field, maxNameLength)})
+			return
+		}
+		*name.target = value
+	}
+	
+	if req.Position != nil {
+		if !models.ValidPosition(*req.Position) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid position"})
+			return
+		}
+		user.Position = *req.Position
+	}
+	
+	if req.Visibility != nil {
+		if req.Visibility.AgeBand != nil {
+			user.Visibility.AgeBand = *req.Visibility.AgeBand
+		}
+		if req.Visibility.GamesPlayed != nil {
+			user.Visibility.GamesPlayed = *req.Visibility.GamesPlayed
+		}
+	}
+	
+	user.UpdatedAt = time.Now()
+	if err := Repos.Users.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+	
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// GetPublicProfile returns a user's public profile by user ID. It only shows
+// the fields the user has made visible.
+func GetPublicProfile(c *gin.Context) {
+	user, err := Repos.Users.GetByID(c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && user.IsDeleted()) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	
+	profile := models.PublicProfileResponse{
+		ID:          user.ID,
+		DisplayName: user.PublicName(),
+		Position:    user.Position,
+	}
+	if user.Visibility.AgeBand {
+		profile.AgeBand = ageBand(user.DOB, time.Now())
+	}
+	if user.Visibility.GamesPlayed {
+		played, err := Repos.Participants.CountPlayed(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count games"})
+			return
+		}
+		profile.GamesPlayed = &played
+	}
+	
+	rating, ok := loadRating(c, user.ID)
+	if !ok {
+		return
+	}
+	profile.Rating = rating
+	
+	c.JSON(http.StatusOK, profile)
+}
+
+// ageBand returns the age range a date of birth falls in at a given time
+func ageBand(dob, now time.Time) string {
+	age := now.Year() - dob.Year()
+	if now.Month() < dob.Month() || (now.Month() == dob.Month() && now.Day() < dob.Day()) {
+		age--
+	}
 	
-	c.JSON(http.StatusOK, models.UserResponse{
-		ID:        user.ID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		DOB:       user.DOB,
-		Phone:     user.Phone,
-		CreatedAt: user.CreatedAt,
-	})
+	switch {
+	case age < 18:
+		return "under 18"
+	case age < 25:
+		return "18-24"
+	case age < 35:
+		return "25-34"
+	case age < 45:
+		return "35-44"
+	case age < 55:
+		return "45-54"
+	case age < 65:
+		return "55-64"
+	default:
+		return "65+"
+	}
+}
+
+// newUserResponse builds the API representation of a user
+func newUserResponse(user models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:          user.ID,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		DOB:         user.DOB,
+		Phone:       user.Phone,
+		Email:       user.Email,
+		DisplayName: user.PublicName(),
+		Visibility:  user.Visibility,
+		Position:    user.Position,
+		Role:        user.Role,
+		CreatedAt:   user.CreatedAt,
+	}
 }
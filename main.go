@@ -1,23 +1,96 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	
 	"rondo/config"
 	"rondo/handlers"
+	"rondo/middleware"
+	"rondo/models"
+	"rondo/repository"
 	"rondo/routes"
 	"rondo/utils"
 )
 
 func main() {
+	normalizePhones := flag.Bool("normalize-phones", false, "rewrite stored phone numbers in E.164 form and exit")
+	grantAdmin := flag.String("grant-admin", "", "give the admin role to the user with this phone number and exit")
+	flag.Parse()
+	
 	// Load environment variables from .env file
 	config.LoadEnv()
+	cfg := config.Load()
+	
+	// Read phone numbers without a country code as numbers of the configured region
+	if err := utils.InitPhoneRegion(cfg.PhoneRegion); err != nil {
+		log.Fatalf("Invalid phone region: %v", err)
+	}
 
-	// Initialize Twilio client
-	twilioClient := utils.InitTwilio()
+	// Open the configured storage backend and apply migrations
+	repos, err := repository.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s storage: %v", cfg.StorageBackend, err)
+	}
+	defer repos.Close()
+	
+	// Normalize phone numbers stored before numbers were validated
+	if *normalizePhones {
+		report, err := repository.NormalizePhones(repos.Users, utils.NormalizePhone)
+		if err != nil {
+			log.Fatalf("Failed to normalize phone numbers: %v", err)
+		}
+		log.Printf("Normalized %d phone numbers", report.Updated)
+		for _, id := range report.Invalid {
+			log.Printf("User %s has an invalid phone number", id)
+		}
+		for _, id := range report.Conflicts {
+			log.Printf("User %s has the same phone number as another user and must be merged by hand", id)
+		}
+		return
+	}
+	
+	// Bootstrap the first admin, who can then manage roles through the API
+	if *grantAdmin != "" {
+		if err := grantAdminRole(repos, *grantAdmin); err != nil {
+			log.Fatalf("Failed to grant admin role: %v", err)
+		}
+		log.Printf("Granted the admin role to %s", *grantAdmin)
+		return
+	}
+
+	// Load the token signing keys and rotate them on schedule
+	if err := utils.InitKeys(cfg.JWTKeysDir, cfg.JWTKeyAlgorithm, cfg.JWTKeyRotation, cfg.JWTKeyGrace); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	utils.Keys.StartRotation(time.Hour)
+
+	// Initialize the configured SMS sender
+	sms, err := utils.NewSMSSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up %s SMS sender: %v", cfg.SMSProvider, err)
+	}
+
+	// Initialize the configured email sender
+	email, err := utils.NewEmailSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up %s email sender: %v", cfg.EmailProvider, err)
+	}
+	handlers.EmailLoginURL = cfg.EmailLoginURL
 	
 	// Initialize handlers
-	handlers.InitHandlers(twilioClient)
+	handlers.InitHandlers(sms, email, repos)
+	middleware.InitMiddleware(repos)
+
+	// Mark games as completed once their end time has passed
+	handlers.StartGameCompletion(time.Minute)
+
+	// Keep recurring series generated for the rolling horizon
+	handlers.StartSeriesGeneration(time.Hour)
 
 	// Setup router
 	r := gin.Default()
@@ -28,3 +101,19 @@ func main() {
 	// Start the server
 	r.Run(":8080")
 }
+
+// grantAdminRole gives the admin role to the user with a phone number
+func grantAdminRole(repos *repository.Repositories, phone string) error {
+	phone, err := utils.NormalizePhone(phone)
+	if err != nil {
+		return err
+	}
+	user, err := repos.Users.GetByPhone(phone)
+	if err != nil {
+		return fmt.Errorf("user %s: %w", phone, err)
+	}
+	
+	user.Role = models.RoleAdmin
+	user.UpdatedAt = time.Now()
+	return repos.Users.Update(user)
+}
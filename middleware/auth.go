@@ -1,16 +1,36 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	
+	"rondo/repository"
 	"rondo/utils"
 )
 
-// AuthMiddleware is a middleware for JWT authentication
-func AuthMiddleware() gin.HandlerFunc {
+// Sessions is used to reject access tokens whose session has been revoked
+var Sessions repository.SessionRepository
+
+// Users is used to reject suspended users and look up their current role
+var Users repository.UserRepository
+
+// sessionTouchInterval limits how often a session's last use is written
+const sessionTouchInterval = time.Minute
+
+// InitMiddleware initializes the middleware
+func InitMiddleware(repos *repository.Repositories) {
+	Sessions = repos.Sessions
+	Users = repos.Users
+}
+
+// AuthMiddleware is a middleware for JWT authentication. It only accepts
+// tokens with the given scope: utils.ScopeUser for the API and
+// utils.ScopeRegister for registration.
+func AuthMiddleware(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -41,12 +61,68 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 		
+		// Reject tokens issued for another part of the API
+		if claims.Scope != scope {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token is not valid for this endpoint"})
+			c.Abort()
+			return
+		}
+		
+		// The role is read from the user rather than the token, so role
+		// changes and suspensions apply immediately. Suspension is checked
+		// first since it also revokes the user's sessions.
+		role := claims.Role
+		if scope == utils.ScopeUser {
+			user, err := Users.GetByID(claims.UserID)
+			if err != nil || user.IsDeleted() {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+				c.Abort()
+				return
+			}
+			if user.IsSuspended() {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended", "reason": user.SuspendedReason})
+				c.Abort()
+				return
+			}
+			role = user.Role
+		}
+		
+		// User tokens are only valid while their session is
+		if scope == utils.ScopeUser && (claims.SessionID == "" || !checkSession(claims.SessionID)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+			c.Abort()
+			return
+		}
+		
 		// Set user info in context
 		c.Set("userID", claims.UserID)
 		c.Set("phone", claims.Phone)
 		c.Set("firstName", claims.FirstName)
 		c.Set("lastName", claims.LastName)
+		c.Set("role", role)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("tokenID", claims.ID)
+		c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
 		
 		c.Next()
 	}
 }
+
+// checkSession reports whether a session is still active, recording its use
+func checkSession(sessionID string) bool {
+	session, err := Sessions.Get(sessionID)
+	if err != nil {
+		return false
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		return false
+	}
+	if now.Sub(session.LastUsedAt) > sessionTouchInterval {
+		if err := Sessions.Touch(sessionID, now); err != nil {
+			log.Printf("Failed to record use of session %s: %v", sessionID, err)
+		}
+	}
+	return true
+}
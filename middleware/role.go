@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+)
+
+// RequireRole only lets users with at least the given role through. It must
+// run after AuthMiddleware.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !models.RoleAtLeast(c.GetString("role"), role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action requires the " + role + " role"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"rondo/models"
+)
+
+func TestRequireOrganizerRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for role, want := range map[string]int{
+		models.RolePlayer:    http.StatusForbidden,
+		models.RoleOrganizer: http.StatusOK,
+		models.RoleAdmin:     http.StatusOK,
+		"":                   http.StatusForbidden,
+	} {
+		r := gin.New()
+		r.POST("/clubs/create", func(c *gin.Context) {
+			c.Set("role", role)
+		}, RequireRole(models.RoleOrganizer), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/clubs/create", nil))
+		if w.Code != want {
+			t.Errorf("role %q: status %d, want %d", role, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// AccountExport is everything stored about a user, as returned by the
+// personal data export
+type AccountExport struct {
+	Profile        AdminUserResponse     `json:"profile"`
+	Sessions       []SessionResponse     `json:"sessions"`
+	CreatedGames   []GameResponse        `json:"created_games"`
+	CreatedSeries  []GameSeries          `json:"created_series"`
+	Participations []ParticipationExport `json:"participations"`
+	GameChanges    []GameChange          `json:"game_changes"` // Edits the user made to games
+	RatingChanges  []RatingChange        `json:"rating_changes"`
+	Following      []Follow              `json:"following"`
+	Followers      []Follow              `json:"followers"`
+	Clubs          []ClubMember          `json:"clubs"`
+	JoinRequests   []ClubJoinRequest     `json:"club_join_requests"`
+	// CalendarFeedCreatedAt is set if the user has a calendar feed. The feed
+	// secret itself is never stored.
+	CalendarFeedCreatedAt *time.Time `json:"calendar_feed_created_at,omitempty"`
+	ExportedAt            time.Time  `json:"exported_at"`
+}
+
+// ParticipationExport is a game the user joined, waited for, left or was
+// removed from
+type ParticipationExport struct {
+	Participant
+	EventName string    `json:"event_name"`
+	Location  string    `json:"location"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// AccountDeletionResponse reports how an account was deleted
+type AccountDeletionResponse struct {
+	Message string `json:"message"`
+	// Anonymized is true if the account took part in games and was stripped of
+	// personal data rather than removed, so those games keep their history
+	Anonymized bool `json:"anonymized"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// CalendarToken grants read access to a user's calendar feed. Only a hash of
+// the secret is stored, so a lost token can be revoked but never recovered.
+type CalendarToken struct {
+	UserID    string
+	TokenHash string
+	CreatedAt time.Time
+}
+
+// CalendarFeedResponse represents a newly issued calendar feed subscription
+type CalendarFeedResponse struct {
+	FeedURL   string    `json:"feed_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import "time"
+
+// Club is a group of players who play together. Clubs can own games, which
+// the club's admins manage alongside the game's creator.
+type Club struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Club member roles. Every club has exactly one owner.
+const (
+	ClubRoleOwner  = "owner"
+	ClubRoleAdmin  = "admin"
+	ClubRoleMember = "member"
+)
+
+// ClubMember links a user to a club they belong to
+type ClubMember struct {
+	ClubID   string    `json:"club_id"`
+	UserID   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// IsAdmin reports whether the member can manage the club and its games
+func (m ClubMember) IsAdmin() bool {
+	return m.Role == ClubRoleOwner || m.Role == ClubRoleAdmin
+}
+
+// Club join request statuses
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// ClubJoinRequest is a user's request to become a member of a club. A user
+// has at most one request per club; asking again after a rejection replaces it.
+type ClubJoinRequest struct {
+	ClubID    string    `json:"club_id"`
+	UserID    string    `json:"user_id"`
+	Message   string    `json:"message,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	DecidedBy string    `json:"decided_by,omitempty"`
+	DecidedAt time.Time `json:"decided_at,omitempty"`
+}
+
+// ClubCreationRequest represents the request to create a club. The signed-in
+// user becomes its owner.
+type ClubCreationRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// ClubUpdateRequest represents a partial update to a club. Omitted fields are left unchanged.
+type ClubUpdateRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// JoinClubRequest represents a request to join a club
+type JoinClubRequest struct {
+	Message string `json:"message"` // Optional note for the club's admins
+}
+
+// ClubRoleRequest changes a member's role. Making a member the owner hands
+// over ownership, and the previous owner becomes an admin.
+type ClubRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ClubResponse represents a club as seen by the signed-in user
+type ClubResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	MemberCount int       `json:"member_count"`
+	Role        string    `json:"role,omitempty"`         // The signed-in user's role, if they are a member
+	JoinRequest string    `json:"join_request,omitempty"` // Status of the signed-in user's join request, if they are not
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ClubListResponse lists clubs
+type ClubListResponse struct {
+	Clubs []ClubResponse `json:"clubs"`
+}
+
+// ClubMemberResponse represents a member in a club's member list
+type ClubMemberResponse struct {
+	UserID      string    `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	Role        string    `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// ClubMemberListResponse lists a club's members, the owner and admins first
+type ClubMemberListResponse struct {
+	ClubID  string               `json:"club_id"`
+	Members []ClubMemberResponse `json:"members"`
+}
+
+// ClubJoinRequestResponse represents a request to join a club
+type ClubJoinRequestResponse struct {
+	UserID      string    `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	Message     string    `json:"message,omitempty"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ClubJoinRequestListResponse lists a club's pending join requests, oldest first
+type ClubJoinRequestListResponse struct {
+	ClubID   string                    `json:"club_id"`
+	Requests []ClubJoinRequestResponse `json:"requests"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Follow records that one user follows another. Two users who follow each
+// other are friends.
+type Follow struct {
+	FollowerID string    `json:"follower_id"`
+	FolloweeID string    `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FollowResponse is a user in a follower or following list
+type FollowResponse struct {
+	UserID      string    `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	Friend      bool      `json:"friend"` // The two users follow each other
+	Since       time.Time `json:"since"`
+}
+
+// FollowListResponse lists a user's followers, the users they follow or
+// their friends, most recent first
+type FollowListResponse struct {
+	UserID string           `json:"user_id"`
+	Users  []FollowResponse `json:"users"`
+}
+
+// GameInviteRequest invites friends of a game's creator to the game
+type GameInviteRequest struct {
+	UserIDs []string `json:"user_ids"` // Friends to invite; omitted to invite every friend
+}
+
+// GameInviteResponse lists the friends who were sent an invitation
+type GameInviteResponse struct {
+	GameID  string   `json:"game_id"`
+	Invited []string `json:"invited"`
+}
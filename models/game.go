@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -11,22 +12,89 @@ type Game struct {
 	StartTime           time.Time `json:"start_time" binding:"required"`
 	EndTime             time.Time `json:"end_time" binding:"required"`
 	Location            string    `json:"location" binding:"required"`
+	Coordinates         *GeoPoint `json:"coordinates,omitempty"` // Optional map position of the location
 	CostPerPerson       float64   `json:"cost_per_person" binding:"required"`
 	PlayerRequirement   int       `json:"player_requirement" binding:"required"`
 	CurrentParticipants int       `json:"current_participants"`
 	CreatorID           string    `json:"creator_id" binding:"required"`
+	Status              string    `json:"status"`
+	CancelReason        string    `json:"cancel_reason,omitempty"`
+	SeriesID            string    `json:"series_id,omitempty"`
+	ClubID              string    `json:"club_id,omitempty"`       // Club that owns the game, whose admins can manage it
+	MembersOnly         bool      `json:"members_only,omitempty"`  // Only members of the owning club can see the game
+	RecurrenceID        time.Time `json:"recurrence_id,omitempty"` // Original start of a series occurrence
+	Sequence            int       `json:"sequence"`                // Revision number, bumped by every edit or status change
 	CreatedAt           time.Time `json:"created_at,omitempty"`
 	UpdatedAt           time.Time `json:"updated_at,omitempty"`
 }
 
+// GeoPoint is a position on the map in decimal degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Validate returns an error if the point lies outside the valid coordinate ranges
+func (p GeoPoint) Validate() error {
+	if p.Latitude < -90 || p.Latitude > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// Game statuses
+const (
+	GameScheduled = "scheduled"
+	GameConfirmed = "confirmed"
+	GameCancelled = "cancelled"
+	GameCompleted = "completed"
+)
+
+// gameTransitions lists the statuses each game status may move to.
+// Cancelled and completed games are final.
+var gameTransitions = map[string][]string{
+	GameScheduled: {GameConfirmed, GameCancelled, GameCompleted},
+	GameConfirmed: {GameCancelled, GameCompleted},
+}
+
+// IsValidGameStatus reports whether status is a known game status
+func IsValidGameStatus(status string) bool {
+	switch status {
+	case GameScheduled, GameConfirmed, GameCancelled, GameCompleted:
+		return true
+	}
+	return false
+}
+
+// ValidateGameTransition returns an error if a game cannot move from one status to another
+func ValidateGameTransition(from, to string) error {
+	for _, allowed := range gameTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change game status from %s to %s", from, to)
+}
+
+// IsOpen reports whether a game still accepts changes to its roster
+func (g Game) IsOpen() bool {
+	return g.Status == GameScheduled || g.Status == GameConfirmed
+}
+
 // GameCreationRequest represents the request to create a new game
 type GameCreationRequest struct {
-	EventName     string  `json:"event_name" binding:"required"`
-	StartTime     string  `json:"start_time" binding:"required"` // Format: YYYY-MM-DDThh:mm:ss
-	EndTime       string  `json:"end_time" binding:"required"`   // Format: YYYY-MM-DDThh:mm:ss
-	Location      string  `json:"location" binding:"required"`
-	CostPerPerson float64 `json:"cost_per_person" binding:"required"`
-	PlayerRequirement   int     `json:"player_requirement" binding:"required"`
+	EventName         string    `json:"event_name" binding:"required"`
+	StartTime         string    `json:"start_time" binding:"required"` // Format: YYYY-MM-DDThh:mm:ss
+	EndTime           string    `json:"end_time" binding:"required"`   // Format: YYYY-MM-DDThh:mm:ss
+	Location          string    `json:"location" binding:"required"`
+	Coordinates       *GeoPoint `json:"coordinates"`
+	CostPerPerson     float64   `json:"cost_per_person" binding:"required"`
+	PlayerRequirement int       `json:"player_requirement" binding:"required"`
+	ClubID            string    `json:"club_id"`      // Optional club to create the game for; requires being one of its admins
+	MembersOnly       bool      `json:"members_only"` // Hide the game from users outside the club
 	// CreatorID comes from the JWT token
 }
 
@@ -37,16 +105,71 @@ type GameResponse struct {
 	StartTime           time.Time `json:"start_time"`
 	EndTime             time.Time `json:"end_time"`
 	Location            string    `json:"location"`
+	Coordinates         *GeoPoint `json:"coordinates,omitempty"`
 	CostPerPerson       float64   `json:"cost_per_person"`
 	PlayerRequirement   int       `json:"player_requirement"`
 	CurrentParticipants int       `json:"current_participants"`
 	CreatorID           string    `json:"creator_id"`
+	Status              string    `json:"status"`
+	CancelReason        string    `json:"cancel_reason,omitempty"`
+	SeriesID            string    `json:"series_id,omitempty"`
+	ClubID              string    `json:"club_id,omitempty"`
+	MembersOnly         bool      `json:"members_only,omitempty"`
 	CreatedAt           time.Time `json:"created_at"`
 }
 
-// GameListResponse represents a list of games
+// GameListResponse represents a page of games. NextCursor is set when more games follow.
 type GameListResponse struct {
-	Games []GameResponse `json:"games"`
+	Games      []GameResponse `json:"games"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// Game list sort keys
+const (
+	SortByStartTime = "start_time"
+	SortByCost      = "cost"
+)
+
+// GameFilter selects, orders and pages games. Zero values leave a criterion unset.
+type GameFilter struct {
+	Statuses     []string
+	StartAfter   time.Time // Inclusive
+	StartBefore  time.Time // Exclusive
+	Location     string    // Case-insensitive substring
+	MaxCost      *float64
+	HasOpenSpots bool
+	CreatorID    string
+	MemberID     string // Games created or joined by this user
+	FollowedBy   string // Games created by users this user follows
+	ClubID       string
+	VisibleTo    string // Leaves out the members-only games this user cannot see
+	PublicOnly   bool   // Leaves out every members-only game
+	// GeohashPrefixes keeps games whose coordinates fall in one of these geohash cells
+	GeohashPrefixes []string
+	SortBy          string // SortByStartTime or SortByCost; ties are broken by ID
+	Descending      bool
+	Limit           int
+	After           *GameCursor
+}
+
+// GameCursor marks the last game of a page so the next page can resume after it
+type GameCursor struct {
+	SortBy     string    `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	StartTime  time.Time `json:"t"`
+	Cost       float64   `json:"c"`
+	ID         string    `json:"id"`
+}
+
+// NearbyGameResponse represents a game together with its distance from the searched point
+type NearbyGameResponse struct {
+	GameResponse
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// NearbyGamesResponse represents the games around a point, nearest first
+type NearbyGamesResponse struct {
+	Games []NearbyGameResponse `json:"games"`
 }
 
 // JoinGameRequest represents a request to join a game
@@ -54,3 +177,36 @@ type JoinGameRequest struct {
 	GameID string `json:"game_id" binding:"required"`
 	// UserID comes from the JWT token
 }
+
+// CancelGameRequest represents a request to cancel a game
+type CancelGameRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// GameUpdateRequest represents a partial update to a game. Omitted fields are left unchanged.
+type GameUpdateRequest struct {
+	EventName         *string   `json:"event_name"`
+	StartTime         *string   `json:"start_time"` // Format: YYYY-MM-DDThh:mm:ssZ
+	EndTime           *string   `json:"end_time"`   // Format: YYYY-MM-DDThh:mm:ssZ
+	Location          *string   `json:"location"`
+	Coordinates       *GeoPoint `json:"coordinates"`
+	CostPerPerson     *float64  `json:"cost_per_person"`
+	PlayerRequirement *int      `json:"player_requirement"`
+	MembersOnly       *bool     `json:"members_only"` // Club games only
+}
+
+// GameChange records a single field edited on a game
+type GameChange struct {
+	GameID    string    `json:"game_id"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// GameHistoryResponse represents the change history of a game, oldest first
+type GameHistoryResponse struct {
+	GameID  string       `json:"game_id"`
+	Changes []GameChange `json:"changes"`
+}
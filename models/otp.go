@@ -13,8 +13,27 @@ type OTPVerify struct {
 	OTP         string `json:"otp" binding:"required"`
 }
 
-// OTPData stores OTP information
+// OTPData stores the OTP state of a phone number, or of a user's pending
+// phone change. The code itself is never
+// stored, only a salted hash of it. The record outlives the code so that
+// lockouts keep applying to new codes.
 type OTPData struct {
-	OTP       string
-	CreatedAt time.Time
+	CodeHash    string    // Empty once the code is used or invalidated
+	Salt        string
+	CreatedAt   time.Time // When the current code was sent
+	Attempts    int       // Failed verifications of the current code
+	Lockouts    int       // Lockouts so far, each longer than the last
+	LockedUntil time.Time
+	// Target is the number a phone change code was sent to, empty for sign-in codes
+	Target string
 }
+
+// Machine-readable OTP error codes
+const (
+	OTPErrorNotFound         = "otp_not_found"
+	OTPErrorExpired          = "otp_expired"
+	OTPErrorInvalid          = "otp_invalid"
+	OTPErrorAttemptsExceeded = "otp_attempts_exceeded"
+	OTPErrorLocked           = "otp_locked"
+	OTPErrorResendCooldown   = "otp_resend_cooldown"
+)
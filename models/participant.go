@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Participant statuses
+const (
+	ParticipantJoined     = "joined"
+	ParticipantWaitlisted = "waitlisted"
+	ParticipantLeft       = "left"
+	ParticipantRemoved    = "removed"
+)
+
+// Participant links a user to a game they have joined or are waiting to join.
+// Waitlisted participants are promoted in JoinedAt order when a spot opens.
+type Participant struct {
+	GameID    string    `json:"game_id"`
+	UserID    string    `json:"user_id"`
+	Status    string    `json:"status"`
+	JoinedAt  time.Time `json:"joined_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Sequence counts the changes to the participation, so calendar feeds can
+	// tell clients when a game was added to or dropped from the user's calendar
+	Sequence int `json:"sequence"`
+}
+
+// ParticipantResponse represents a participant in a game roster
+type ParticipantResponse struct {
+	UserID      string    `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	Status      string    `json:"status"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// ParticipantListResponse represents the roster of a game
+type ParticipantListResponse struct {
+	GameID       string                `json:"game_id"`
+	Participants []ParticipantResponse `json:"participants"`
+}
+
+// WaitlistPositionResponse represents a user's place on a game's waitlist
+type WaitlistPositionResponse struct {
+	GameID   string    `json:"game_id"`
+	Position int       `json:"position"`
+	JoinedAt time.Time `json:"joined_at"`
+}
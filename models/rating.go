@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Rating is a player's skill rating in the Glicko-2 system. Users who have
+// never played a rated game have no stored rating and start from the
+// defaults.
+type Rating struct {
+	UserID     string
+	Rating     float64
+	Deviation  float64 // Uncertainty of the rating; shrinks as more games are rated
+	Volatility float64 // Expected fluctuation of the rating
+	GamesRated int
+	UpdatedAt  time.Time
+}
+
+// RatingChange records how one game's result changed a player's rating
+type RatingChange struct {
+	UserID          string    `json:"user_id"`
+	GameID          string    `json:"game_id"`
+	Score           float64   `json:"score"` // Mean score against the other teams: 1 won, 0.5 drew, 0 lost
+	RatingBefore    float64   `json:"rating_before"`
+	RatingAfter     float64   `json:"rating_after"`
+	DeviationBefore float64   `json:"deviation_before"`
+	DeviationAfter  float64   `json:"deviation_after"`
+	VolatilityAfter float64   `json:"volatility_after"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// GameResult is the outcome of a completed game as submitted by its creator
+type GameResult struct {
+	GameID      string     `json:"game_id"`
+	Teams       [][]string `json:"teams"`  // User IDs of each side
+	Winner      int        `json:"winner"` // Index into Teams, or -1 for a draw
+	SubmittedBy string     `json:"submitted_by"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+}
+
+// GameResultRequest submits the outcome of a completed game. Every player
+// must be on the game's roster.
+type GameResultRequest struct {
+	Teams  [][]string `json:"teams"`  // Defaults to the teams last picked for the game
+	Winner *int       `json:"winner"` // Index into Teams; omitted for a draw
+}
+
+// GameResultResponse is a game's result together with the rating changes it caused
+type GameResultResponse struct {
+	GameResult
+	RatingChanges []RatingChange `json:"rating_changes"`
+}
+
+// RatingResponse is a player's rating as shown on profiles. Provisional
+// ratings are still too uncertain to compare players by.
+type RatingResponse struct {
+	Rating      int  `json:"rating"`
+	Deviation   int  `json:"deviation"`
+	GamesRated  int  `json:"games_rated"`
+	Provisional bool `json:"provisional"`
+}
+
+// RatingHistoryResponse lists a player's rating changes, newest first
+type RatingHistoryResponse struct {
+	UserID  string         `json:"user_id"`
+	Rating  RatingResponse `json:"rating"`
+	Changes []RatingChange `json:"changes"`
+}
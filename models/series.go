@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// Series statuses
+const (
+	SeriesActive    = "active"
+	SeriesCancelled = "cancelled"
+)
+
+// GameSeries is a recurring game. Concrete games are generated from the
+// template fields for each occurrence of the recurrence rule.
+type GameSeries struct {
+	ID                string    `json:"id"`
+	CreatorID         string    `json:"creator_id"`
+	RRule             string    `json:"rrule"`
+	Timezone          string    `json:"timezone"`
+	EventName         string    `json:"event_name"`
+	Location          string    `json:"location"`
+	Coordinates       *GeoPoint `json:"coordinates,omitempty"`
+	CostPerPerson     float64   `json:"cost_per_person"`
+	PlayerRequirement int       `json:"player_requirement"`
+	StartTime         time.Time `json:"start_time"` // Start of the first occurrence (DTSTART)
+	EndTime           time.Time `json:"end_time"`   // End of the first occurrence
+	Status            string    `json:"status"`
+	ClubID            string    `json:"club_id,omitempty"`      // Club that owns the series and its games
+	MembersOnly       bool      `json:"members_only,omitempty"` // Generated games are only visible to club members
+	// EndsBefore stops generation at occurrences starting at or after it; zero means no end
+	EndsBefore time.Time `json:"ends_before,omitempty"`
+	// GeneratedUntil is the exclusive end of the window games have been generated for
+	GeneratedUntil time.Time `json:"generated_until"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// SeriesCreationRequest represents the request to create a recurring game series.
+// StartTime and EndTime describe the first occurrence.
+type SeriesCreationRequest struct {
+	GameCreationRequest
+	RRule    string `json:"rrule" binding:"required"` // e.g. FREQ=WEEKLY;BYDAY=TU,TH;COUNT=20
+	Timezone string `json:"timezone"`                 // IANA name, e.g. Europe/London; defaults to the start time's offset
+}
+
+// SeriesCancelRequest represents a request to cancel a series from an occurrence onwards
+type SeriesCancelRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	// FromGameID is the first occurrence to cancel; empty cancels every upcoming occurrence
+	FromGameID string `json:"from_game_id"`
+}
+
+// SeriesResponse represents a series together with its generated games
+type SeriesResponse struct {
+	Series GameSeries     `json:"series"`
+	Games  []GameResponse `json:"games"`
+}
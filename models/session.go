@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Session is a signed-in device. Access tokens name their session, so
+// revoking it logs the device out. Refresh tokens rotate on every use; only
+// a hash of the current and previous refresh secrets is stored.
+type Session struct {
+	ID                string
+	UserID            string
+	TokenHash         string
+	PreviousTokenHash string // Presenting this again means the refresh token was stolen
+	UserAgent         string
+	IPAddress         string
+	CreatedAt         time.Time
+	LastUsedAt        time.Time
+	ExpiresAt         time.Time // Sessions expire when they go unrefreshed this long
+	RevokedAt         time.Time // Zero while the session is active
+}
+
+// IsActive reports whether the session may still be used at a given time
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
+
+// RefreshRequest represents a request to exchange a refresh token for new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// SessionResponse represents a signed-in device
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // Whether this is the session making the request
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// TeamSplit is a division of a game's roster into teams. Each game keeps the
+// split its creator picked last.
+type TeamSplit struct {
+	GameID       string
+	Teams        [][]string // User IDs of each team
+	KeepTogether [][]string
+	KeepApart    [][]string
+	CreatedBy    string
+	CreatedAt    time.Time
+}
+
+// TeamSplitRequest splits a game's joined players into teams. Omitted fields
+// keep the values of the game's previous split, so an empty request reshuffles
+// the teams with the same settings.
+type TeamSplitRequest struct {
+	Count        int        `json:"count"`         // Number of teams, 2 if there was no previous split
+	KeepTogether [][]string `json:"keep_together"` // Groups of players who must share a team
+	KeepApart    [][]string `json:"keep_apart"`    // Groups of players who must all be on different teams
+}
+
+// TeamSplitResponse is a game's team split
+type TeamSplitResponse struct {
+	GameID       string         `json:"game_id"`
+	Teams        []TeamResponse `json:"teams"`
+	KeepTogether [][]string     `json:"keep_together"`
+	KeepApart    [][]string     `json:"keep_apart"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// TeamResponse is one team of a split with the players' current ratings
+type TeamResponse struct {
+	Players     []TeamPlayerResponse `json:"players"`
+	TotalRating int                  `json:"total_rating"`
+}
+
+// TeamPlayerResponse is a player on a team
+type TeamPlayerResponse struct {
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+	Position    string `json:"position,omitempty"`
+	Rating      int    `json:"rating"`
+}
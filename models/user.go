@@ -4,6 +4,51 @@ import (
 	"time"
 )
 
+// User roles, from least to most privileged. Every user can create and join
+// games and run recurring series. Organizers can also start clubs, and admins
+// can also moderate users and games.
+const (
+	RolePlayer    = "player"
+	RoleOrganizer = "organizer"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders the roles by privilege
+var roleRanks = map[string]int{
+	RolePlayer:    1,
+	RoleOrganizer: 2,
+	RoleAdmin:     3,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of required
+func RoleAtLeast(role, required string) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[required]
+}
+
+// Preferred positions players can choose on their profile. Team balancing
+// spreads players of the same position across the teams.
+const (
+	PositionGoalkeeper = "goalkeeper"
+	PositionDefender   = "defender"
+	PositionMidfielder = "midfielder"
+	PositionForward    = "forward"
+)
+
+// ValidPosition reports whether position is a known position. The empty
+// string means the player has no preference.
+func ValidPosition(position string) bool {
+	switch position {
+	case "", PositionGoalkeeper, PositionDefender, PositionMidfielder, PositionForward:
+		return true
+	}
+	return false
+}
+
 // User represents a user in the system
 type User struct {
 	ID        string    `json:"id,omitempty"`
@@ -11,10 +56,52 @@ type User struct {
 	LastName  string    `json:"last_name" binding:"required"`
 	DOB       time.Time `json:"dob" binding:"required"`
 	Phone     string    `json:"phone,omitempty"`
+	// Email is empty until the user verifies an address to sign in with
+	Email string `json:"email,omitempty"`
+	// DisplayName is shown on the public profile; empty means the first name
+	// and last initial
+	DisplayName string            `json:"display_name,omitempty"`
+	Visibility  ProfileVisibility `json:"visibility"`
+	Position    string            `json:"position,omitempty"` // Preferred position, empty for no preference
+	Role        string            `json:"role"`
+	// SuspendedAt is zero unless an admin has suspended the account
+	SuspendedAt     time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string    `json:"suspended_reason,omitempty"`
+	// DeletedAt is zero unless the user deleted their account and it was
+	// anonymized rather than removed
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
 
+// IsSuspended reports whether the account has been suspended
+func (u User) IsSuspended() bool {
+	return !u.SuspendedAt.IsZero()
+}
+
+// IsDeleted reports whether the account has been deleted
+func (u User) IsDeleted() bool {
+	return !u.DeletedAt.IsZero()
+}
+
+// PublicName returns the name shown to other users
+func (u User) PublicName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	for _, initial := range u.LastName {
+		return u.FirstName + " " + string(initial) + "."
+	}
+	return u.FirstName
+}
+
+// ProfileVisibility chooses what other users see on a public profile besides
+// the display name. Everything is hidden until the user shows it.
+type ProfileVisibility struct {
+	AgeBand     bool `json:"age_band"`
+	GamesPlayed bool `json:"games_played"`
+}
+
 // UserRegistrationRequest represents the request to register a new user
 type UserRegistrationRequest struct {
 	FirstName string `json:"first_name" binding:"required"`
@@ -23,6 +110,37 @@ type UserRegistrationRequest struct {
 	// Phone number comes from the JWT token
 }
 
+// PhoneChangeRequest starts a change of the signed-in user's phone number
+type PhoneChangeRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
+
+// EmailRequest attaches an email address to the signed-in user
+type EmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// EmailVerify completes an email change with the code sent to the address
+type EmailVerify struct {
+	OTP string `json:"otp" binding:"required"`
+}
+
+// EmailLoginRequest asks for a login link to be sent to a verified address
+type EmailLoginRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// EmailLoginVerify signs in with the code from a login email
+type EmailLoginVerify struct {
+	Email string `json:"email" binding:"required"`
+	OTP   string `json:"otp" binding:"required"`
+}
+
+// PhoneChangeVerify completes a phone change with the code sent to the new number
+type PhoneChangeVerify struct {
+	OTP string `json:"otp" binding:"required"`
+}
+
 // UserResponse represents the response after user registration
 type UserResponse struct {
 	ID        string    `json:"id"`
@@ -30,5 +148,92 @@ type UserResponse struct {
 	LastName  string    `json:"last_name"`
 	DOB       time.Time `json:"dob"`
 	Phone     string    `json:"phone"`
-	CreatedAt time.Time `json:"created_at"`
+	Email     string    `json:"email,omitempty"`
+	// DisplayName is the name other users see
+	DisplayName string            `json:"display_name"`
+	Visibility  ProfileVisibility `json:"visibility"`
+	Position    string            `json:"position,omitempty"`
+	Role        string            `json:"role"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ProfileUpdateRequest changes the signed-in user's profile. Omitted fields
+// are left unchanged; an empty display name restores the default and an
+// empty position clears the preference.
+type ProfileUpdateRequest struct {
+	FirstName   *string                  `json:"first_name"`
+	LastName    *string                  `json:"last_name"`
+	DisplayName *string                  `json:"display_name"`
+	Position    *string                  `json:"position"`
+	Visibility  *ProfileVisibilityUpdate `json:"visibility"`
+}
+
+// ProfileVisibilityUpdate changes some of a user's visibility settings
+type ProfileVisibilityUpdate struct {
+	AgeBand     *bool `json:"age_band"`
+	GamesPlayed *bool `json:"games_played"`
+}
+
+// PublicProfileResponse is a user as shown to other users. Fields the user
+// has not made visible are omitted.
+type PublicProfileResponse struct {
+	ID          string         `json:"id"`
+	DisplayName string         `json:"display_name"`
+	AgeBand     string         `json:"age_band,omitempty"` // e.g. "25-34"
+	Position    string         `json:"position,omitempty"`
+	GamesPlayed *int           `json:"games_played,omitempty"`
+	Rating      RatingResponse `json:"rating"`
+}
+
+// AdminUserResponse is a user as shown to admins
+type AdminUserResponse struct {
+	UserResponse
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string     `json:"suspended_reason,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// UserListResponse is a page of users
+type UserListResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// UserFilter selects users for the admin user search
+type UserFilter struct {
+	Query     string // Case-insensitive substring of the name or phone number
+	Role      string
+	Suspended *bool
+	Limit     int
+	After     *UserCursor // Users are ordered by creation time, ties broken by ID
+}
+
+// UserCursor marks the last user of a page so the next page can resume after it
+type UserCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// RoleUpdateRequest changes a user's role
+type RoleUpdateRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// SuspendUserRequest suspends a user's account
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// SystemStats summarizes the state of the system for admins
+type SystemStats struct {
+	Users          int            `json:"users"`
+	UsersByRole    map[string]int `json:"users_by_role"`
+	SuspendedUsers int            `json:"suspended_users"`
+	Games          int            `json:"games"`
+	GamesByStatus  map[string]int `json:"games_by_status"`
+	UpcomingGames  int            `json:"upcoming_games"`
+	Participants   int            `json:"participants"` // Joined players across upcoming games
+	ActiveSessions int            `json:"active_sessions"`
+	GeneratedAt    time.Time      `json:"generated_at"`
 }
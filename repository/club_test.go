@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rondo/models"
+)
+
+// createClub stores a club owned by the given user
+func createClub(t *testing.T, repos *Repositories, id, ownerID string, at time.Time) {
+	t.Helper()
+
+	club := models.Club{ID: id, Name: id, CreatedAt: at, UpdatedAt: at}
+	owner := models.ClubMember{ClubID: id, UserID: ownerID, Role: models.ClubRoleOwner, JoinedAt: at}
+	if err := repos.Clubs.Create(club, owner); err != nil {
+		t.Fatalf("create club %s: %v", id, err)
+	}
+}
+
+// requestToJoin stores a pending join request
+func requestToJoin(t *testing.T, repos *Repositories, clubID, userID string, at time.Time) {
+	t.Helper()
+
+	request := models.ClubJoinRequest{ClubID: clubID, UserID: userID, Status: models.JoinRequestPending, CreatedAt: at}
+	if err := repos.Clubs.RequestToJoin(request); err != nil {
+		t.Fatalf("request to join %s: %v", clubID, err)
+	}
+}
+
+// decide records the decision on a pending join request
+func decide(repos *Repositories, clubID, userID, status string, at time.Time) error {
+	return repos.Clubs.DecideJoinRequest(models.ClubJoinRequest{
+		ClubID: clubID, UserID: userID, Status: status, CreatedAt: at, DecidedBy: "owner", DecidedAt: at,
+	})
+}
+
+func TestClubJoinRequestsAndMembersOnlyGames(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createUsers(t, repos, "owner", "ana", "ben", "cai")
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+			createClub(t, repos, "club", "owner", at)
+			if err := repos.Clubs.Create(models.Club{ID: "club"}, models.ClubMember{ClubID: "club", UserID: "ana"}); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("second club: got %v, want ErrAlreadyExists", err)
+			}
+
+			for _, game := range []models.Game{
+				{ID: "open", ClubID: "club"},
+				{ID: "private", ClubID: "club", MembersOnly: true},
+				{ID: "other"},
+			} {
+				game.EventName, game.Location, game.PlayerRequirement = "Club game", "Court 1", 10
+				game.StartTime, game.EndTime = at.Add(24*time.Hour), at.Add(26*time.Hour)
+				game.CreatorID, game.Status, game.CreatedAt, game.UpdatedAt = "owner", models.GameScheduled, at, at
+				if err := repos.Games.Create(game); err != nil {
+					t.Fatalf("create game: %v", err)
+				}
+			}
+			countGames := func(filter models.GameFilter) int {
+				t.Helper()
+				games, err := repos.Games.Search(filter)
+				if err != nil {
+					t.Fatalf("search: %v", err)
+				}
+				return len(games)
+			}
+
+			requestToJoin(t, repos, "club", "ana", at.Add(time.Minute))
+			requestToJoin(t, repos, "club", "ben", at.Add(2*time.Minute))
+			if err := repos.Clubs.RequestToJoin(models.ClubJoinRequest{ClubID: "club", UserID: "ana", Status: models.JoinRequestPending, CreatedAt: at}); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("second pending request: got %v, want ErrAlreadyExists", err)
+			}
+			if err := repos.Clubs.RequestToJoin(models.ClubJoinRequest{ClubID: "nowhere", UserID: "ana", Status: models.JoinRequestPending, CreatedAt: at}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("request to unknown club: got %v, want ErrNotFound", err)
+			}
+
+			if got := countGames(models.GameFilter{ClubID: "club"}); got != 2 {
+				t.Errorf("club has %d games, want 2", got)
+			}
+			if got := countGames(models.GameFilter{PublicOnly: true}); got != 2 {
+				t.Errorf("%d public games, want 2", got)
+			}
+			if got := countGames(models.GameFilter{ClubID: "club", VisibleTo: "ana"}); got != 1 {
+				t.Errorf("ana sees %d club games before joining, want 1", got)
+			}
+
+			if err := decide(repos, "club", "ana", models.JoinRequestApproved, at.Add(time.Hour)); err != nil {
+				t.Fatalf("approve: %v", err)
+			}
+			if err := decide(repos, "club", "ben", models.JoinRequestRejected, at.Add(time.Hour)); err != nil {
+				t.Fatalf("reject: %v", err)
+			}
+			if err := decide(repos, "club", "ana", models.JoinRequestApproved, at.Add(time.Hour)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deciding twice: got %v, want ErrNotFound", err)
+			}
+
+			for userID, want := range map[string]int{"owner": 3, "ana": 3, "ben": 2} {
+				if got := countGames(models.GameFilter{VisibleTo: userID}); got != want {
+					t.Errorf("%s sees %d games, want %d", userID, got, want)
+				}
+			}
+
+			// Asking again after a rejection replaces the decided request
+			requestToJoin(t, repos, "club", "ben", at.Add(2*time.Hour))
+			pending, err := repos.Clubs.ListJoinRequests("club", models.JoinRequestPending)
+			if err != nil {
+				t.Fatalf("list requests: %v", err)
+			}
+			if len(pending) != 1 || pending[0].UserID != "ben" || !pending[0].CreatedAt.Equal(at.Add(2*time.Hour)) {
+				t.Errorf("pending requests %+v, want ben's new request", pending)
+			}
+
+			members, err := repos.Clubs.ListMembers("club")
+			if err != nil {
+				t.Fatalf("list members: %v", err)
+			}
+			if len(members) != 2 || members[0].UserID != "owner" || members[1].UserID != "ana" || members[1].Role != models.ClubRoleMember {
+				t.Errorf("members %+v, want the owner then ana", members)
+			}
+
+			// A failed role change leaves every role as it was
+			err = repos.Clubs.UpdateMembers([]models.ClubMember{
+				{ClubID: "club", UserID: "ana", Role: models.ClubRoleAdmin},
+				{ClubID: "club", UserID: "cai", Role: models.ClubRoleAdmin},
+			})
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("promoting a non-member: got %v, want ErrNotFound", err)
+			}
+			if member, _ := repos.Clubs.GetMember("club", "ana"); member.Role != models.ClubRoleMember {
+				t.Errorf("ana's role changed to %s", member.Role)
+			}
+		})
+	}
+}
+
+func TestClubIsDeletedWithItsLastMember(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createUsers(t, repos, "owner", "ana", "ben", "cai")
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+			createClub(t, repos, "club", "owner", at)
+			createClub(t, repos, "solo", "cai", at)
+			requestToJoin(t, repos, "club", "ana", at)
+			requestToJoin(t, repos, "club", "ben", at)
+			if err := decide(repos, "club", "ana", models.JoinRequestApproved, at); err != nil {
+				t.Fatalf("approve: %v", err)
+			}
+
+			if err := repos.Clubs.DeleteByUser("ana"); err != nil {
+				t.Fatalf("delete memberships: %v", err)
+			}
+			if clubs, _ := repos.Clubs.ListByUser("ana"); len(clubs) != 0 {
+				t.Errorf("ana is still in %+v", clubs)
+			}
+			if _, err := repos.Clubs.Get("club"); err != nil {
+				t.Errorf("club with members left: %v", err)
+			}
+
+			if err := repos.Clubs.RemoveMember("club", "owner"); err != nil {
+				t.Fatalf("remove owner: %v", err)
+			}
+			if err := repos.Clubs.RemoveMember("club", "owner"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second removal: got %v, want ErrNotFound", err)
+			}
+			if _, err := repos.Clubs.Get("club"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("club without members: got %v, want ErrNotFound", err)
+			}
+			if _, err := repos.Clubs.GetJoinRequest("club", "ben"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("request to deleted club: got %v, want ErrNotFound", err)
+			}
+
+			if err := repos.Users.Delete("cai"); err != nil {
+				t.Fatalf("delete user: %v", err)
+			}
+			if _, err := repos.Clubs.Get("solo"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("club of deleted user: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFollowedMembersOnlyGamesHiddenFromNonMembers(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createUsers(t, repos, "owner", "ana", "ben")
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+			createClub(t, repos, "club", "owner", at)
+			requestToJoin(t, repos, "club", "ana", at)
+			if err := decide(repos, "club", "ana", models.JoinRequestApproved, at); err != nil {
+				t.Fatalf("approve: %v", err)
+			}
+
+			for _, game := range []models.Game{
+				{ID: "open", ClubID: "club"},
+				{ID: "private", ClubID: "club", MembersOnly: true},
+			} {
+				game.EventName, game.Location, game.PlayerRequirement = "Club game", "Court 1", 10
+				game.StartTime, game.EndTime = at.Add(24*time.Hour), at.Add(26*time.Hour)
+				game.CreatorID, game.Status, game.CreatedAt, game.UpdatedAt = "owner", models.GameScheduled, at, at
+				if err := repos.Games.Create(game); err != nil {
+					t.Fatalf("create game: %v", err)
+				}
+			}
+			for _, followerID := range []string{"ana", "ben"} {
+				if err := repos.Follows.Follow(models.Follow{FollowerID: followerID, FolloweeID: "owner", CreatedAt: at}); err != nil {
+					t.Fatalf("follow: %v", err)
+				}
+			}
+
+			for userID, want := range map[string]int{"ana": 2, "ben": 1} {
+				games, err := repos.Games.Search(models.GameFilter{FollowedBy: userID, VisibleTo: userID})
+				if err != nil {
+					t.Fatalf("search: %v", err)
+				}
+				if len(games) != want {
+					t.Errorf("%s sees %d games from people they follow, want %d", userID, len(games), want)
+				}
+			}
+		})
+	}
+}
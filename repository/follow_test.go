@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"rondo/models"
+)
+
+// createUsers stores players with the given IDs
+func createUsers(t *testing.T, repos *Repositories, ids ...string) {
+	t.Helper()
+
+	now := time.Now()
+	for i, id := range ids {
+		user := models.User{ID: id, FirstName: id, LastName: "Test", Phone: fmt.Sprintf("+1415555%04d", i),
+			Role: models.RolePlayer, CreatedAt: now, UpdatedAt: now}
+		if err := repos.Users.Create(user); err != nil {
+			t.Fatalf("create user %s: %v", id, err)
+		}
+	}
+}
+
+func TestFollowsAndFollowedByFilter(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createUsers(t, repos, "ana", "ben", "cai", "creator")
+			createGame(t, repos, "game", 10)
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+
+			for i, follow := range []models.Follow{
+				{FollowerID: "ana", FolloweeID: "creator"},
+				{FollowerID: "ben", FolloweeID: "creator"},
+				{FollowerID: "creator", FolloweeID: "ana"},
+			} {
+				follow.CreatedAt = at.Add(time.Duration(i) * time.Minute)
+				if err := repos.Follows.Follow(follow); err != nil {
+					t.Fatalf("follow: %v", err)
+				}
+			}
+			if err := repos.Follows.Follow(models.Follow{FollowerID: "ana", FolloweeID: "creator", CreatedAt: at}); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("second follow: got %v, want ErrAlreadyExists", err)
+			}
+			if err := repos.Follows.Follow(models.Follow{FollowerID: "ana", FolloweeID: "nobody", CreatedAt: at}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("follow unknown user: got %v, want ErrNotFound", err)
+			}
+
+			followers, err := repos.Follows.ListFollowers("creator")
+			if err != nil {
+				t.Fatalf("list followers: %v", err)
+			}
+			if len(followers) != 2 || followers[0].FollowerID != "ben" || followers[1].FollowerID != "ana" {
+				t.Errorf("followers %+v are not newest first", followers)
+			}
+
+			for follower, want := range map[string]int{"ana": 1, "ben": 1, "cai": 0} {
+				games, err := repos.Games.Search(models.GameFilter{FollowedBy: follower})
+				if err != nil {
+					t.Fatalf("search: %v", err)
+				}
+				if len(games) != want {
+					t.Errorf("%s sees %d games from people they follow, want %d", follower, len(games), want)
+				}
+			}
+
+			if err := repos.Follows.Unfollow("ben", "creator"); err != nil {
+				t.Fatalf("unfollow: %v", err)
+			}
+			if err := repos.Follows.Unfollow("ben", "creator"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("second unfollow: got %v, want ErrNotFound", err)
+			}
+
+			if err := repos.Follows.DeleteByUser("creator"); err != nil {
+				t.Fatalf("delete follows: %v", err)
+			}
+			for _, userID := range []string{"ana", "creator"} {
+				following, _ := repos.Follows.ListFollowing(userID)
+				followers, _ := repos.Follows.ListFollowers(userID)
+				if len(following)+len(followers) != 0 {
+					t.Errorf("%s still has follows %v %v", userID, following, followers)
+				}
+			}
+		})
+	}
+}
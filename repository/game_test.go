@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"rondo/models"
+)
+
+func TestCompleteEndedBumpsSequence(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", 10)
+
+			// Calendar clients only replace an event whose sequence has grown
+			completed, err := repos.Games.CompleteEnded(time.Now().Add(48 * time.Hour))
+			if err != nil {
+				t.Fatalf("complete ended: %v", err)
+			}
+			if completed != 1 {
+				t.Errorf("completed %d games, want 1", completed)
+			}
+
+			game, err := repos.Games.Get("game")
+			if err != nil {
+				t.Fatalf("get game: %v", err)
+			}
+			if game.Status != models.GameCompleted || game.Sequence != 1 {
+				t.Errorf("game is %s at sequence %d, want completed at sequence 1", game.Status, game.Sequence)
+			}
+		})
+	}
+}
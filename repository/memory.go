@@ -0,0 +1,1456 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rondo/models"
+)
+
+// memoryStore holds every in-memory table behind a single lock so that
+// operations spanning several tables stay consistent.
+type memoryStore struct {
+	mu           sync.RWMutex
+	users        map[string]models.User                       // user ID -> user
+	games        map[string]models.Game                       // game ID -> game
+	series       map[string]models.GameSeries                 // series ID -> series
+	participants map[string]map[string]models.Participant     // game ID -> user ID -> participant
+	history      map[string][]models.GameChange               // game ID -> changes, oldest first
+	calendars    map[string]models.CalendarToken              // user ID -> calendar token
+	sessions     map[string]models.Session                    // session ID -> session
+	otps         map[string]models.OTPData                    // phone -> OTP
+	usedTokens   map[string]time.Time                         // token ID -> expiry
+	ratings      map[string]models.Rating                     // user ID -> rating
+	results      map[string]models.GameResult                 // game ID -> result
+	teams        map[string]models.TeamSplit                  // game ID -> team split
+	follows      map[string]map[string]time.Time              // follower ID -> followee ID -> followed at
+	clubs        map[string]models.Club                       // club ID -> club
+	clubMembers  map[string]map[string]models.ClubMember      // club ID -> user ID -> member
+	joinRequests map[string]map[string]models.ClubJoinRequest // club ID -> user ID -> join request
+	changes      []models.RatingChange                        // rating changes, oldest first
+	geoIndex     map[string]map[string]bool                   // geohash prefix -> IDs of games in that cell
+}
+
+// NewMemory returns repositories that keep everything in process memory.
+// Data is lost on restart, which makes this backend suitable for tests and
+// local development only.
+func NewMemory() *Repositories {
+	store := &memoryStore{
+		users:        make(map[string]models.User),
+		games:        make(map[string]models.Game),
+		series:       make(map[string]models.GameSeries),
+		participants: make(map[string]map[string]models.Participant),
+		history:      make(map[string][]models.GameChange),
+		calendars:    make(map[string]models.CalendarToken),
+		sessions:     make(map[string]models.Session),
+		otps:         make(map[string]models.OTPData),
+		usedTokens:   make(map[string]time.Time),
+		ratings:      make(map[string]models.Rating),
+		results:      make(map[string]models.GameResult),
+		teams:        make(map[string]models.TeamSplit),
+		follows:      make(map[string]map[string]time.Time),
+		clubs:        make(map[string]models.Club),
+		clubMembers:  make(map[string]map[string]models.ClubMember),
+		joinRequests: make(map[string]map[string]models.ClubJoinRequest),
+		geoIndex:     make(map[string]map[string]bool),
+	}
+
+	return &Repositories{
+		Users:        &memoryUserRepository{store},
+		Games:        &memoryGameRepository{store},
+		Series:       &memorySeriesRepository{store},
+		Participants: &memoryParticipantRepository{store},
+		History:      &memoryGameHistoryRepository{store},
+		Calendars:    &memoryCalendarTokenRepository{store},
+		Sessions:     &memorySessionRepository{store},
+		OTPs:         &memoryOTPRepository{store},
+		UsedTokens:   &memoryUsedTokenRepository{store},
+		Ratings:      &memoryRatingRepository{store},
+		Follows:      &memoryFollowRepository{store},
+		Clubs:        &memoryClubRepository{store},
+		Stats:        &memoryStatsRepository{store},
+	}
+}
+
+// joinedCount returns the number of joined participants in a game.
+// The caller must hold the store lock.
+func (s *memoryStore) joinedCount(gameID string) int {
+	count := 0
+	for _, participant := range s.participants[gameID] {
+		if participant.Status == models.ParticipantJoined {
+			count++
+		}
+	}
+	return count
+}
+
+// indexGame adds a game to or removes it from every cell of the geo index
+// that contains it. The caller must hold the store lock.
+func (s *memoryStore) indexGame(game models.Game, add bool) {
+	hash := gameGeohash(game)
+	for length := 1; length <= len(hash); length++ {
+		prefix := hash[:length]
+		if !add {
+			delete(s.geoIndex[prefix], game.ID)
+			continue
+		}
+		if s.geoIndex[prefix] == nil {
+			s.geoIndex[prefix] = make(map[string]bool)
+		}
+		s.geoIndex[prefix][game.ID] = true
+	}
+}
+
+// memoryUserRepository is an in-memory UserRepository
+type memoryUserRepository struct {
+	*memoryStore
+}
+
+func (r *memoryUserRepository) Create(user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.ID]; exists {
+		return ErrAlreadyExists
+	}
+	for _, existing := range r.users {
+		if existing.Phone == user.Phone || (user.Email != "" && existing.Email == user.Email) {
+			return ErrAlreadyExists
+		}
+	}
+
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *memoryUserRepository) GetByID(id string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) GetByPhone(phone string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Phone == phone {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) GetByEmail(email string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if email != "" && user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) Update(user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[user.ID]; !exists {
+		return ErrNotFound
+	}
+	for _, existing := range r.users {
+		if existing.ID != user.ID && (existing.Phone == user.Phone || (user.Email != "" && existing.Email == user.Email)) {
+			return ErrAlreadyExists
+		}
+	}
+
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *memoryUserRepository) List() ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].ID < users[j].ID
+		}
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users, nil
+}
+
+func (r *memoryUserRepository) Search(filter models.UserFilter) ([]models.User, error) {
+	users, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.ToLower(filter.Query)
+	var matched []models.User
+	for _, user := range users {
+		if query != "" && !strings.Contains(strings.ToLower(user.FirstName+" "+user.LastName), query) &&
+			!strings.Contains(user.Phone, query) {
+			continue
+		}
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if filter.Suspended != nil && user.IsSuspended() != *filter.Suspended {
+			continue
+		}
+		if after := filter.After; after != nil && (user.CreatedAt.Before(after.CreatedAt) ||
+			(user.CreatedAt.Equal(after.CreatedAt) && user.ID <= after.ID)) {
+			continue
+		}
+
+		matched = append(matched, user)
+		if filter.Limit > 0 && len(matched) == filter.Limit {
+			break
+		}
+	}
+	return matched, nil
+}
+
+func (r *memoryUserRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[id]; !exists {
+		return ErrNotFound
+	}
+
+	delete(r.users, id)
+	delete(r.calendars, id)
+	r.deleteFollows(id)
+	r.deleteClubMemberships(id)
+	for sessionID, session := range r.sessions {
+		if session.UserID == id {
+			delete(r.sessions, sessionID)
+		}
+	}
+	return nil
+}
+
+// memoryGameRepository is an in-memory GameRepository
+type memoryGameRepository struct {
+	*memoryStore
+}
+
+func (r *memoryGameRepository) Create(game models.Game) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.games[game.ID]; exists {
+		return ErrAlreadyExists
+	}
+
+	game.CurrentParticipants = 0
+	r.games[game.ID] = game
+	r.indexGame(game, true)
+	return nil
+}
+
+func (r *memoryGameRepository) Get(id string) (models.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	game, exists := r.games[id]
+	if !exists {
+		return models.Game{}, ErrNotFound
+	}
+	game.CurrentParticipants = r.joinedCount(id)
+	return game, nil
+}
+
+func (r *memoryGameRepository) Update(game models.Game) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, exists := r.games[game.ID]
+	if !exists {
+		return ErrNotFound
+	}
+
+	r.indexGame(previous, false)
+	r.games[game.ID] = game
+	r.indexGame(game, true)
+	return nil
+}
+
+func (r *memoryGameRepository) Search(filter models.GameFilter) ([]models.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var games []models.Game
+	for _, game := range r.candidates(filter) {
+		game.CurrentParticipants = r.joinedCount(game.ID)
+		if r.matchesGameFilter(game, filter) {
+			games = append(games, game)
+		}
+	}
+
+	sort.Slice(games, func(i, j int) bool {
+		return compareGames(games[i], games[j], filter.SortBy, filter.Descending) < 0
+	})
+
+	if filter.Limit > 0 && len(games) > filter.Limit {
+		games = games[:filter.Limit]
+	}
+	return games, nil
+}
+
+// candidates returns the games a filter may match, using the geo index to
+// narrow them down when the filter selects geohash cells.
+// The caller must hold the store lock.
+func (r *memoryGameRepository) candidates(filter models.GameFilter) map[string]models.Game {
+	if len(filter.GeohashPrefixes) == 0 {
+		return r.games
+	}
+
+	games := make(map[string]models.Game)
+	for _, prefix := range filter.GeohashPrefixes {
+		for id := range r.geoIndex[prefix] {
+			games[id] = r.games[id]
+		}
+	}
+	return games
+}
+
+func (r *memoryGameRepository) ListBySeries(seriesID string) ([]models.Game, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var games []models.Game
+	for _, game := range r.games {
+		if game.SeriesID == seriesID {
+			game.CurrentParticipants = r.joinedCount(game.ID)
+			games = append(games, game)
+		}
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].StartTime.Before(games[j].StartTime)
+	})
+	return games, nil
+}
+
+func (r *memoryGameRepository) CompleteEnded(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	completed := 0
+	for id, game := range r.games {
+		if game.IsOpen() && game.EndTime.Before(now) {
+			game.Status = models.GameCompleted
+			game.Sequence++
+			game.UpdatedAt = now
+			r.games[id] = game
+			completed++
+		}
+	}
+	return completed, nil
+}
+
+func (r *memoryGameRepository) GetTeams(gameID string) (models.TeamSplit, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	split, exists := r.teams[gameID]
+	if !exists {
+		return models.TeamSplit{}, ErrNotFound
+	}
+	return split, nil
+}
+
+func (r *memoryGameRepository) SaveTeams(split models.TeamSplit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.games[split.GameID]; !exists {
+		return ErrNotFound
+	}
+	r.teams[split.GameID] = split
+	return nil
+}
+
+// matchesGameFilter reports whether a game satisfies every criterion of a filter,
+// including coming after the filter's cursor. The caller must hold the store lock.
+func (s *memoryStore) matchesGameFilter(game models.Game, filter models.GameFilter) bool {
+	if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, game.Status) {
+		return false
+	}
+	if !filter.StartAfter.IsZero() && game.StartTime.Before(filter.StartAfter) {
+		return false
+	}
+	if !filter.StartBefore.IsZero() && !game.StartTime.Before(filter.StartBefore) {
+		return false
+	}
+	if filter.Location != "" && !strings.Contains(strings.ToLower(game.Location), strings.ToLower(filter.Location)) {
+		return false
+	}
+	if filter.MaxCost != nil && game.CostPerPerson > *filter.MaxCost {
+		return false
+	}
+	if filter.HasOpenSpots && game.CurrentParticipants >= game.PlayerRequirement {
+		return false
+	}
+	if filter.CreatorID != "" && game.CreatorID != filter.CreatorID {
+		return false
+	}
+	if filter.MemberID != "" && game.CreatorID != filter.MemberID && s.participants[game.ID][filter.MemberID].Status != models.ParticipantJoined {
+		return false
+	}
+	if _, follows := s.follows[filter.FollowedBy][game.CreatorID]; filter.FollowedBy != "" && !follows {
+		return false
+	}
+	if filter.ClubID != "" && game.ClubID != filter.ClubID {
+		return false
+	}
+	if game.MembersOnly && (filter.PublicOnly || (filter.VisibleTo != "" && !s.canSeeGame(game, filter.VisibleTo))) {
+		return false
+	}
+	if filter.After != nil {
+		cursor := models.Game{ID: filter.After.ID, StartTime: filter.After.StartTime, CostPerPerson: filter.After.Cost}
+		if compareGames(game, cursor, filter.SortBy, filter.Descending) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// canSeeGame reports whether a user can see a members-only game: they
+// created it, belong to its club or are on its roster or waitlist. The caller
+// must hold the store lock.
+func (s *memoryStore) canSeeGame(game models.Game, userID string) bool {
+	if game.CreatorID == userID {
+		return true
+	}
+	if _, member := s.clubMembers[game.ClubID][userID]; member {
+		return true
+	}
+	status := s.participants[game.ID][userID].Status
+	return status == models.ParticipantJoined || status == models.ParticipantWaitlisted
+}
+
+// compareGames orders two games by a sort key, breaking ties by ID
+func compareGames(a, b models.Game, sortBy string, descending bool) int {
+	var result int
+	if sortBy == models.SortByCost {
+		result = cmp.Compare(a.CostPerPerson, b.CostPerPerson)
+	} else {
+		result = a.StartTime.Compare(b.StartTime)
+	}
+	if result == 0 {
+		result = strings.Compare(a.ID, b.ID)
+	}
+	if descending {
+		return -result
+	}
+	return result
+}
+
+// memorySeriesRepository is an in-memory SeriesRepository
+type memorySeriesRepository struct {
+	*memoryStore
+}
+
+func (r *memorySeriesRepository) Create(series models.GameSeries) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.series[series.ID]; exists {
+		return ErrAlreadyExists
+	}
+
+	r.series[series.ID] = series
+	return nil
+}
+
+func (r *memorySeriesRepository) Get(id string) (models.GameSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	series, exists := r.series[id]
+	if !exists {
+		return models.GameSeries{}, ErrNotFound
+	}
+	return series, nil
+}
+
+func (r *memorySeriesRepository) Update(series models.GameSeries) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.series[series.ID]
+	if !exists {
+		return ErrNotFound
+	}
+
+	series.GeneratedUntil = stored.GeneratedUntil
+	r.series[series.ID] = series
+	return nil
+}
+
+func (r *memorySeriesRepository) AdvanceGenerated(id string, until, at time.Time) (models.GameSeries, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series, exists := r.series[id]
+	if !exists {
+		return models.GameSeries{}, ErrNotFound
+	}
+
+	if series.Status == models.SeriesActive {
+		series.GeneratedUntil = until
+		series.UpdatedAt = at
+		r.series[id] = series
+	}
+	return series, nil
+}
+
+func (r *memorySeriesRepository) ListActive() ([]models.GameSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var active []models.GameSeries
+	for _, series := range r.series {
+		if series.Status == models.SeriesActive {
+			active = append(active, series)
+		}
+	}
+	return active, nil
+}
+
+func (r *memorySeriesRepository) ListByCreator(creatorID string) ([]models.GameSeries, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var created []models.GameSeries
+	for _, series := range r.series {
+		if series.CreatorID == creatorID {
+			created = append(created, series)
+		}
+	}
+	sort.Slice(created, func(i, j int) bool {
+		return created[i].CreatedAt.Before(created[j].CreatedAt)
+	})
+	return created, nil
+}
+
+// memoryParticipantRepository is an in-memory ParticipantRepository
+type memoryParticipantRepository struct {
+	*memoryStore
+}
+
+func (r *memoryParticipantRepository) Add(participant models.Participant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	roster, exists := r.participants[participant.GameID]
+	if !exists {
+		roster = make(map[string]models.Participant)
+		r.participants[participant.GameID] = roster
+	}
+	if _, exists := roster[participant.UserID]; exists {
+		return ErrAlreadyExists
+	}
+
+	roster[participant.UserID] = participant
+	return nil
+}
+
+func (r *memoryParticipantRepository) Get(gameID, userID string) (models.Participant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	participant, exists := r.participants[gameID][userID]
+	if !exists {
+		return models.Participant{}, ErrNotFound
+	}
+	return participant, nil
+}
+
+func (r *memoryParticipantRepository) ListByGame(gameID string) ([]models.Participant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	participants := make([]models.Participant, 0, len(r.participants[gameID]))
+	for _, participant := range r.participants[gameID] {
+		participants = append(participants, participant)
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].JoinedAt.Before(participants[j].JoinedAt)
+	})
+	return participants, nil
+}
+
+func (r *memoryParticipantRepository) ListByUser(userID string) ([]models.Participant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var participants []models.Participant
+	for _, roster := range r.participants {
+		if participant, exists := roster[userID]; exists {
+			participants = append(participants, participant)
+		}
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].JoinedAt.Before(participants[j].JoinedAt)
+	})
+	return participants, nil
+}
+
+func (r *memoryParticipantRepository) CountPlayed(userID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for gameID, roster := range r.participants {
+		if roster[userID].Status == models.ParticipantJoined && r.games[gameID].Status == models.GameCompleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryParticipantRepository) Update(participant models.Participant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.participants[participant.GameID][participant.UserID]; !exists {
+		return ErrNotFound
+	}
+
+	r.participants[participant.GameID][participant.UserID] = participant
+	return nil
+}
+
+func (r *memoryParticipantRepository) Transition(gameID, userID, from, to string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	participant, exists := r.participants[gameID][userID]
+	if !exists || participant.Status != from {
+		return ErrNotFound
+	}
+
+	participant.Status = to
+	participant.UpdatedAt = at
+	participant.Sequence++
+	r.participants[gameID][userID] = participant
+	return nil
+}
+
+func (r *memoryParticipantRepository) Join(gameID, userID string, at time.Time) (models.Participant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, exists := r.games[gameID]
+	if !exists {
+		return models.Participant{}, ErrNotFound
+	}
+	existing, rejoining := r.participants[gameID][userID]
+	if rejoining && existing.Status != models.ParticipantLeft {
+		return models.Participant{}, ErrAlreadyExists
+	}
+
+	participant := models.Participant{
+		GameID:    gameID,
+		UserID:    userID,
+		Status:    models.ParticipantJoined,
+		JoinedAt:  at,
+		UpdatedAt: at,
+	}
+	if rejoining {
+		participant.Sequence = existing.Sequence + 1
+	}
+	if r.joinedCount(gameID) >= game.PlayerRequirement {
+		participant.Status = models.ParticipantWaitlisted
+	}
+
+	if r.participants[gameID] == nil {
+		r.participants[gameID] = make(map[string]models.Participant)
+	}
+	r.participants[gameID][userID] = participant
+	return participant, nil
+}
+
+func (r *memoryParticipantRepository) PromoteWaitlisted(gameID string, at time.Time) ([]models.Participant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	game, exists := r.games[gameID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	var waitlist []models.Participant
+	for _, participant := range r.participants[gameID] {
+		if participant.Status == models.ParticipantWaitlisted {
+			waitlist = append(waitlist, participant)
+		}
+	}
+	sort.Slice(waitlist, func(i, j int) bool {
+		return waitlist[i].JoinedAt.Before(waitlist[j].JoinedAt)
+	})
+
+	var promoted []models.Participant
+	for free := game.PlayerRequirement - r.joinedCount(gameID); free > 0 && len(promoted) < len(waitlist); free-- {
+		participant := waitlist[len(promoted)]
+		participant.Status = models.ParticipantJoined
+		participant.UpdatedAt = at
+		participant.Sequence++
+		r.participants[gameID][participant.UserID] = participant
+		promoted = append(promoted, participant)
+	}
+	return promoted, nil
+}
+
+// memoryGameHistoryRepository is an in-memory GameHistoryRepository
+type memoryGameHistoryRepository struct {
+	*memoryStore
+}
+
+func (r *memoryGameHistoryRepository) Record(changes []models.GameChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, change := range changes {
+		r.history[change.GameID] = append(r.history[change.GameID], change)
+	}
+	return nil
+}
+
+func (r *memoryGameHistoryRepository) ListByGame(gameID string) ([]models.GameChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.GameChange(nil), r.history[gameID]...), nil
+}
+
+func (r *memoryGameHistoryRepository) ListByUser(userID string) ([]models.GameChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var changes []models.GameChange
+	for _, gameChanges := range r.history {
+		for _, change := range gameChanges {
+			if change.ChangedBy == userID {
+				changes = append(changes, change)
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].ChangedAt.Before(changes[j].ChangedAt)
+	})
+	return changes, nil
+}
+
+// memoryCalendarTokenRepository is an in-memory CalendarTokenRepository
+type memoryCalendarTokenRepository struct {
+	*memoryStore
+}
+
+func (r *memoryCalendarTokenRepository) Save(token models.CalendarToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calendars[token.UserID] = token
+	return nil
+}
+
+func (r *memoryCalendarTokenRepository) GetByHash(tokenHash string) (models.CalendarToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, token := range r.calendars {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.CalendarToken{}, ErrNotFound
+}
+
+func (r *memoryCalendarTokenRepository) GetByUser(userID string) (models.CalendarToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, exists := r.calendars[userID]
+	if !exists {
+		return models.CalendarToken{}, ErrNotFound
+	}
+	return token, nil
+}
+
+func (r *memoryCalendarTokenRepository) Delete(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.calendars, userID)
+	return nil
+}
+
+// memorySessionRepository is an in-memory SessionRepository
+type memorySessionRepository struct {
+	*memoryStore
+}
+
+func (r *memorySessionRepository) Create(session models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[session.ID]; exists {
+		return ErrAlreadyExists
+	}
+
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *memorySessionRepository) Get(id string) (models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return models.Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (r *memorySessionRepository) ListByUser(userID string) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt.IsZero() {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) Rotate(id, currentHash, newHash string, expiresAt, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists || !session.RevokedAt.IsZero() || session.TokenHash != currentHash {
+		return ErrNotFound
+	}
+
+	session.PreviousTokenHash = session.TokenHash
+	session.TokenHash = newHash
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = at
+	r.sessions[id] = session
+	return nil
+}
+
+func (r *memorySessionRepository) Touch(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	session.LastUsedAt = at
+	r.sessions[id] = session
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAll(userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt.IsZero() {
+			session.RevokedAt = at
+			r.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) Revoke(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	if session.RevokedAt.IsZero() {
+		session.RevokedAt = at
+		r.sessions[id] = session
+	}
+	return nil
+}
+
+// memoryOTPRepository is an in-memory OTPRepository
+type memoryOTPRepository struct {
+	*memoryStore
+}
+
+func (r *memoryOTPRepository) Update(phone string, fn func(data *models.OTPData, exists bool) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, exists := r.otps[phone]
+	if err := fn(&data, exists); err != nil {
+		return err
+	}
+	r.otps[phone] = data
+	return nil
+}
+
+func (r *memoryOTPRepository) Get(phone string) (models.OTPData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data, exists := r.otps[phone]
+	if !exists {
+		return models.OTPData{}, ErrNotFound
+	}
+	return data, nil
+}
+
+func (r *memoryOTPRepository) Delete(phone string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.otps, phone)
+	return nil
+}
+
+// memoryUsedTokenRepository is an in-memory UsedTokenRepository
+type memoryUsedTokenRepository struct {
+	*memoryStore
+}
+
+func (r *memoryUsedTokenRepository) Use(id string, expiresAt, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for usedID, expiry := range r.usedTokens {
+		if expiry.Before(at) {
+			delete(r.usedTokens, usedID)
+		}
+	}
+	if _, used := r.usedTokens[id]; used {
+		return ErrAlreadyExists
+	}
+
+	r.usedTokens[id] = expiresAt
+	return nil
+}
+
+// memoryRatingRepository is an in-memory RatingRepository
+type memoryRatingRepository struct {
+	*memoryStore
+}
+
+func (r *memoryRatingRepository) Get(userID string) (models.Rating, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rating, exists := r.ratings[userID]
+	if !exists {
+		return models.Rating{}, ErrNotFound
+	}
+	return rating, nil
+}
+
+func (r *memoryRatingRepository) ListByUsers(userIDs []string) (map[string]models.Rating, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.listByUsers(userIDs), nil
+}
+
+// listByUsers returns the stored ratings of the given users. The caller must
+// hold the store lock.
+func (r *memoryRatingRepository) listByUsers(userIDs []string) map[string]models.Rating {
+	ratings := make(map[string]models.Rating)
+	for _, userID := range userIDs {
+		if rating, exists := r.ratings[userID]; exists {
+			ratings[userID] = rating
+		}
+	}
+	return ratings
+}
+
+func (r *memoryRatingRepository) History(userID string, limit int) ([]models.RatingChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var changes []models.RatingChange
+	for i := len(r.changes) - 1; i >= 0 && (limit <= 0 || len(changes) < limit); i-- {
+		if r.changes[i].UserID == userID {
+			changes = append(changes, r.changes[i])
+		}
+	}
+	return changes, nil
+}
+
+func (r *memoryRatingRepository) GetResult(gameID string) (models.GameResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result, exists := r.results[gameID]
+	if !exists {
+		return models.GameResult{}, ErrNotFound
+	}
+	return result, nil
+}
+
+func (r *memoryRatingRepository) ListByGame(gameID string) ([]models.RatingChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var changes []models.RatingChange
+	for _, change := range r.changes {
+		if change.GameID == gameID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (r *memoryRatingRepository) RecordResult(result models.GameResult,
+	rate func(current map[string]models.Rating) []models.RatingChange) ([]models.RatingChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.games[result.GameID]; !exists {
+		return nil, ErrNotFound
+	}
+	if _, exists := r.results[result.GameID]; exists {
+		return nil, ErrAlreadyExists
+	}
+
+	var players []string
+	for _, team := range result.Teams {
+		players = append(players, team...)
+	}
+	changes := rate(r.listByUsers(players))
+
+	r.results[result.GameID] = result
+	for _, change := range changes {
+		rating := r.ratings[change.UserID]
+		rating.UserID = change.UserID
+		rating.Rating = change.RatingAfter
+		rating.Deviation = change.DeviationAfter
+		rating.Volatility = change.VolatilityAfter
+		rating.GamesRated++
+		rating.UpdatedAt = change.ChangedAt
+		r.ratings[change.UserID] = rating
+		r.changes = append(r.changes, change)
+	}
+	return changes, nil
+}
+
+// memoryFollowRepository is an in-memory FollowRepository
+type memoryFollowRepository struct {
+	*memoryStore
+}
+
+func (r *memoryFollowRepository) Follow(follow models.Follow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[follow.FollowerID]; !exists {
+		return ErrNotFound
+	}
+	if _, exists := r.users[follow.FolloweeID]; !exists {
+		return ErrNotFound
+	}
+	if _, exists := r.follows[follow.FollowerID][follow.FolloweeID]; exists {
+		return ErrAlreadyExists
+	}
+	if r.follows[follow.FollowerID] == nil {
+		r.follows[follow.FollowerID] = make(map[string]time.Time)
+	}
+	r.follows[follow.FollowerID][follow.FolloweeID] = follow.CreatedAt
+	return nil
+}
+
+func (r *memoryFollowRepository) Unfollow(followerID, followeeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.follows[followerID][followeeID]; !exists {
+		return ErrNotFound
+	}
+	delete(r.follows[followerID], followeeID)
+	return nil
+}
+
+func (r *memoryFollowRepository) ListFollowers(userID string) ([]models.Follow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var follows []models.Follow
+	for followerID, followees := range r.follows {
+		if followedAt, exists := followees[userID]; exists {
+			follows = append(follows, models.Follow{FollowerID: followerID, FolloweeID: userID, CreatedAt: followedAt})
+		}
+	}
+	sortFollows(follows)
+	return follows, nil
+}
+
+func (r *memoryFollowRepository) ListFollowing(userID string) ([]models.Follow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var follows []models.Follow
+	for followeeID, followedAt := range r.follows[userID] {
+		follows = append(follows, models.Follow{FollowerID: userID, FolloweeID: followeeID, CreatedAt: followedAt})
+	}
+	sortFollows(follows)
+	return follows, nil
+}
+
+func (r *memoryFollowRepository) DeleteByUser(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteFollows(userID)
+	return nil
+}
+
+// deleteFollows removes every follow made by or of a user. The caller must
+// hold the store lock.
+func (s *memoryStore) deleteFollows(userID string) {
+	delete(s.follows, userID)
+	for _, followees := range s.follows {
+		delete(followees, userID)
+	}
+}
+
+// sortFollows orders follows newest first, breaking ties by user IDs
+func sortFollows(follows []models.Follow) {
+	sort.Slice(follows, func(i, j int) bool {
+		a, b := follows[i], follows[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		if a.FollowerID != b.FollowerID {
+			return a.FollowerID < b.FollowerID
+		}
+		return a.FolloweeID < b.FolloweeID
+	})
+}
+
+// memoryClubRepository is an in-memory ClubRepository
+type memoryClubRepository struct {
+	*memoryStore
+}
+
+func (r *memoryClubRepository) Create(club models.Club, owner models.ClubMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.clubs[club.ID]; exists {
+		return ErrAlreadyExists
+	}
+	if _, exists := r.users[owner.UserID]; !exists {
+		return ErrNotFound
+	}
+
+	r.clubs[club.ID] = club
+	r.clubMembers[club.ID] = map[string]models.ClubMember{owner.UserID: owner}
+	return nil
+}
+
+func (r *memoryClubRepository) Get(id string) (models.Club, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	club, exists := r.clubs[id]
+	if !exists {
+		return models.Club{}, ErrNotFound
+	}
+	return club, nil
+}
+
+func (r *memoryClubRepository) Update(club models.Club) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.clubs[club.ID]; !exists {
+		return ErrNotFound
+	}
+	r.clubs[club.ID] = club
+	return nil
+}
+
+func (r *memoryClubRepository) GetMember(clubID, userID string) (models.ClubMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	member, exists := r.clubMembers[clubID][userID]
+	if !exists {
+		return models.ClubMember{}, ErrNotFound
+	}
+	return member, nil
+}
+
+func (r *memoryClubRepository) ListMembers(clubID string) ([]models.ClubMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []models.ClubMember
+	for _, member := range r.clubMembers[clubID] {
+		members = append(members, member)
+	}
+	sortClubMembers(members)
+	return members, nil
+}
+
+func (r *memoryClubRepository) ListByUser(userID string) ([]models.ClubMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []models.ClubMember
+	for _, clubMembers := range r.clubMembers {
+		if member, exists := clubMembers[userID]; exists {
+			members = append(members, member)
+		}
+	}
+	sortClubMembers(members)
+	return members, nil
+}
+
+func (r *memoryClubRepository) UpdateMembers(members []models.ClubMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, member := range members {
+		if _, exists := r.clubMembers[member.ClubID][member.UserID]; !exists {
+			return ErrNotFound
+		}
+	}
+	for _, member := range members {
+		r.clubMembers[member.ClubID][member.UserID] = member
+	}
+	return nil
+}
+
+func (r *memoryClubRepository) RemoveMember(clubID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.clubMembers[clubID][userID]; !exists {
+		return ErrNotFound
+	}
+	delete(r.clubMembers[clubID], userID)
+	if len(r.clubMembers[clubID]) == 0 {
+		r.deleteClub(clubID)
+	}
+	return nil
+}
+
+func (r *memoryClubRepository) RequestToJoin(request models.ClubJoinRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.clubs[request.ClubID]; !exists {
+		return ErrNotFound
+	}
+	if _, exists := r.users[request.UserID]; !exists {
+		return ErrNotFound
+	}
+	if existing, exists := r.joinRequests[request.ClubID][request.UserID]; exists && existing.Status == models.JoinRequestPending {
+		return ErrAlreadyExists
+	}
+	if r.joinRequests[request.ClubID] == nil {
+		r.joinRequests[request.ClubID] = make(map[string]models.ClubJoinRequest)
+	}
+	r.joinRequests[request.ClubID][request.UserID] = request
+	return nil
+}
+
+func (r *memoryClubRepository) GetJoinRequest(clubID, userID string) (models.ClubJoinRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	request, exists := r.joinRequests[clubID][userID]
+	if !exists {
+		return models.ClubJoinRequest{}, ErrNotFound
+	}
+	return request, nil
+}
+
+func (r *memoryClubRepository) ListJoinRequests(clubID, status string) ([]models.ClubJoinRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var requests []models.ClubJoinRequest
+	for _, request := range r.joinRequests[clubID] {
+		if request.Status == status {
+			requests = append(requests, request)
+		}
+	}
+	sortJoinRequests(requests)
+	return requests, nil
+}
+
+func (r *memoryClubRepository) ListJoinRequestsByUser(userID string) ([]models.ClubJoinRequest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var requests []models.ClubJoinRequest
+	for _, clubRequests := range r.joinRequests {
+		if request, exists := clubRequests[userID]; exists {
+			requests = append(requests, request)
+		}
+	}
+	sortJoinRequests(requests)
+	return requests, nil
+}
+
+func (r *memoryClubRepository) DecideJoinRequest(request models.ClubJoinRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, exists := r.joinRequests[request.ClubID][request.UserID]
+	if !exists || existing.Status != models.JoinRequestPending {
+		return ErrNotFound
+	}
+
+	r.joinRequests[request.ClubID][request.UserID] = request
+	if request.Status == models.JoinRequestApproved {
+		r.clubMembers[request.ClubID][request.UserID] = models.ClubMember{
+			ClubID:   request.ClubID,
+			UserID:   request.UserID,
+			Role:     models.ClubRoleMember,
+			JoinedAt: request.DecidedAt,
+		}
+	}
+	return nil
+}
+
+func (r *memoryClubRepository) DeleteByUser(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteClubMemberships(userID)
+	return nil
+}
+
+// deleteClubMemberships removes a user's memberships and join requests and
+// deletes the clubs left without members. The caller must hold the store lock.
+func (s *memoryStore) deleteClubMemberships(userID string) {
+	for clubID, members := range s.clubMembers {
+		if _, exists := members[userID]; !exists {
+			continue
+		}
+		delete(members, userID)
+		if len(members) == 0 {
+			s.deleteClub(clubID)
+		}
+	}
+	for _, requests := range s.joinRequests {
+		delete(requests, userID)
+	}
+}
+
+// deleteClub removes a club with its members and join requests. Games the
+// club owned keep their club ID. The caller must hold the store lock.
+func (s *memoryStore) deleteClub(clubID string) {
+	delete(s.clubs, clubID)
+	delete(s.clubMembers, clubID)
+	delete(s.joinRequests, clubID)
+}
+
+// sortClubMembers orders memberships by when they started, breaking ties by
+// club and user IDs
+func sortClubMembers(members []models.ClubMember) {
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if !a.JoinedAt.Equal(b.JoinedAt) {
+			return a.JoinedAt.Before(b.JoinedAt)
+		}
+		if a.ClubID != b.ClubID {
+			return a.ClubID < b.ClubID
+		}
+		return a.UserID < b.UserID
+	})
+}
+
+// sortJoinRequests orders join requests oldest first, breaking ties by club
+// and user IDs
+func sortJoinRequests(requests []models.ClubJoinRequest) {
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.ClubID != b.ClubID {
+			return a.ClubID < b.ClubID
+		}
+		return a.UserID < b.UserID
+	})
+}
+
+// memoryStatsRepository is an in-memory StatsRepository
+type memoryStatsRepository struct {
+	*memoryStore
+}
+
+func (r *memoryStatsRepository) Get(now time.Time) (models.SystemStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := models.SystemStats{
+		Users:         len(r.users),
+		UsersByRole:   make(map[string]int),
+		Games:         len(r.games),
+		GamesByStatus: make(map[string]int),
+		GeneratedAt:   now,
+	}
+	for _, user := range r.users {
+		stats.UsersByRole[user.Role]++
+		if user.IsSuspended() {
+			stats.SuspendedUsers++
+		}
+	}
+	for _, game := range r.games {
+		stats.GamesByStatus[game.Status]++
+		if (game.Status == models.GameScheduled || game.Status == models.GameConfirmed) && game.StartTime.After(now) {
+			stats.UpcomingGames++
+			stats.Participants += r.joinedCount(game.ID)
+		}
+	}
+	for _, session := range r.sessions {
+		if session.IsActive(now) {
+			stats.ActiveSessions++
+		}
+	}
+	return stats, nil
+}
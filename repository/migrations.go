@@ -0,0 +1,401 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// migration is a single versioned schema change
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// migrations lists every schema change in order. Applied versions are recorded
+// in schema_migrations, so existing entries must never be edited; append a new
+// migration instead.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create users, games and otps",
+		statements: []string{
+			`CREATE TABLE users (
+				id         TEXT PRIMARY KEY,
+				first_name TEXT NOT NULL,
+				last_name  TEXT NOT NULL,
+				dob        TIMESTAMP NOT NULL,
+				phone      TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE games (
+				id                   TEXT PRIMARY KEY,
+				event_name           TEXT NOT NULL,
+				start_time           TIMESTAMP NOT NULL,
+				end_time             TIMESTAMP NOT NULL,
+				location             TEXT NOT NULL,
+				cost_per_person      REAL NOT NULL,
+				player_requirement   INTEGER NOT NULL,
+				current_participants INTEGER NOT NULL DEFAULT 0,
+				creator_id           TEXT NOT NULL,
+				created_at           TIMESTAMP NOT NULL,
+				updated_at           TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_games_start_time ON games (start_time)`,
+			`CREATE TABLE otps (
+				phone      TEXT PRIMARY KEY,
+				otp        TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "add game participants",
+		statements: []string{
+			`CREATE TABLE game_participants (
+				game_id    TEXT NOT NULL REFERENCES games (id),
+				user_id    TEXT NOT NULL,
+				status     TEXT NOT NULL,
+				joined_at  TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (game_id, user_id)
+			)`,
+			`CREATE INDEX idx_game_participants_user ON game_participants (user_id)`,
+			// The participant count is now derived from the roster
+			`ALTER TABLE games DROP COLUMN current_participants`,
+		},
+	},
+	{
+		version: 3,
+		name:    "add game status",
+		statements: []string{
+			`ALTER TABLE games ADD COLUMN status TEXT NOT NULL DEFAULT 'scheduled'`,
+			`ALTER TABLE games ADD COLUMN cancel_reason TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX idx_games_status ON games (status)`,
+		},
+	},
+	{
+		version: 4,
+		name:    "add game change history",
+		statements: []string{
+			`CREATE TABLE game_changes (
+				id         INTEGER PRIMARY KEY AUTOINCREMENT,
+				game_id    TEXT NOT NULL REFERENCES games (id),
+				field      TEXT NOT NULL,
+				old_value  TEXT NOT NULL,
+				new_value  TEXT NOT NULL,
+				changed_by TEXT NOT NULL,
+				changed_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_game_changes_game ON game_changes (game_id)`,
+		},
+	},
+	{
+		version: 5,
+		name:    "add recurring game series",
+		statements: []string{
+			`CREATE TABLE game_series (
+				id                 TEXT PRIMARY KEY,
+				creator_id         TEXT NOT NULL,
+				rrule              TEXT NOT NULL,
+				timezone           TEXT NOT NULL,
+				event_name         TEXT NOT NULL,
+				location           TEXT NOT NULL,
+				cost_per_person    REAL NOT NULL,
+				player_requirement INTEGER NOT NULL,
+				start_time         TIMESTAMP NOT NULL,
+				end_time           TIMESTAMP NOT NULL,
+				status             TEXT NOT NULL,
+				ends_before        TIMESTAMP NOT NULL,
+				generated_until    TIMESTAMP NOT NULL,
+				created_at         TIMESTAMP NOT NULL,
+				updated_at         TIMESTAMP NOT NULL
+			)`,
+			`ALTER TABLE games ADD COLUMN series_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE games ADD COLUMN recurrence_id TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'`,
+			`CREATE INDEX idx_games_series ON games (series_id)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add game coordinates",
+		statements: []string{
+			`ALTER TABLE games ADD COLUMN latitude REAL`,
+			`ALTER TABLE games ADD COLUMN longitude REAL`,
+			`ALTER TABLE games ADD COLUMN geohash TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX idx_games_geohash ON games (geohash)`,
+			`ALTER TABLE game_series ADD COLUMN latitude REAL`,
+			`ALTER TABLE game_series ADD COLUMN longitude REAL`,
+		},
+	},
+	{
+		version: 7,
+		name:    "add calendar feeds",
+		statements: []string{
+			`ALTER TABLE games ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+			`CREATE TABLE calendar_tokens (
+				user_id    TEXT PRIMARY KEY REFERENCES users (id),
+				token_hash TEXT NOT NULL UNIQUE,
+				created_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "hash OTPs and track failed attempts",
+		statements: []string{
+			// Pending codes are short-lived, so plaintext ones are dropped rather than migrated
+			`DROP TABLE otps`,
+			`CREATE TABLE otps (
+				phone        TEXT PRIMARY KEY,
+				code_hash    TEXT NOT NULL,
+				salt         TEXT NOT NULL,
+				created_at   TIMESTAMP NOT NULL,
+				attempts     INTEGER NOT NULL,
+				lockouts     INTEGER NOT NULL,
+				locked_until TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "add sessions",
+		statements: []string{
+			`CREATE TABLE sessions (
+				id                  TEXT PRIMARY KEY,
+				user_id             TEXT NOT NULL REFERENCES users (id),
+				token_hash          TEXT NOT NULL,
+				previous_token_hash TEXT NOT NULL,
+				user_agent          TEXT NOT NULL,
+				ip_address          TEXT NOT NULL,
+				created_at          TIMESTAMP NOT NULL,
+				last_used_at        TIMESTAMP NOT NULL,
+				expires_at          TIMESTAMP NOT NULL,
+				revoked_at          TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_sessions_user ON sessions (user_id)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add used tokens",
+		statements: []string{
+			`CREATE TABLE used_tokens (
+				id         TEXT PRIMARY KEY,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 11,
+		name:    "add user roles and suspensions",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'player'`,
+			// The zero time marks accounts that are not suspended
+			`ALTER TABLE users ADD COLUMN suspended_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'`,
+			`ALTER TABLE users ADD COLUMN suspended_reason TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX idx_users_created ON users (created_at, id)`,
+		},
+	},
+	{
+		version: 12,
+		name:    "add phone change targets to otps",
+		statements: []string{
+			`ALTER TABLE otps ADD COLUMN target TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 13,
+		name:    "add user deletion",
+		statements: []string{
+			// The zero time marks accounts that have not been deleted
+			`ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'`,
+			`CREATE INDEX idx_game_changes_changed_by ON game_changes (changed_by)`,
+			`CREATE INDEX idx_game_series_creator ON game_series (creator_id)`,
+		},
+	},
+	{
+		version: 14,
+		name:    "add user emails",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT ''`,
+			// Users without an email all share the empty string
+			`CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE email != ''`,
+		},
+	},
+	{
+		version: 15,
+		name:    "add public profile settings",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE users ADD COLUMN show_age_band INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE users ADD COLUMN show_games_played INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 16,
+		name:    "add ratings",
+		statements: []string{
+			`CREATE TABLE ratings (
+				user_id     TEXT PRIMARY KEY,
+				rating      REAL NOT NULL,
+				deviation   REAL NOT NULL,
+				volatility  REAL NOT NULL,
+				games_rated INTEGER NOT NULL,
+				updated_at  TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE game_results (
+				game_id      TEXT PRIMARY KEY REFERENCES games (id),
+				teams        TEXT NOT NULL,
+				winner       INTEGER NOT NULL,
+				submitted_by TEXT NOT NULL,
+				submitted_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE rating_changes (
+				id               INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id          TEXT NOT NULL,
+				game_id          TEXT NOT NULL REFERENCES games (id),
+				score            REAL NOT NULL,
+				rating_before    REAL NOT NULL,
+				rating_after     REAL NOT NULL,
+				deviation_before REAL NOT NULL,
+				deviation_after  REAL NOT NULL,
+				volatility_after REAL NOT NULL,
+				changed_at       TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX idx_rating_changes_user ON rating_changes (user_id)`,
+			`CREATE INDEX idx_rating_changes_game ON rating_changes (game_id)`,
+		},
+	},
+	{
+		version: 17,
+		name:    "add team splits",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN position TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE game_teams (
+				game_id       TEXT PRIMARY KEY REFERENCES games (id),
+				teams         TEXT NOT NULL,
+				keep_together TEXT NOT NULL,
+				keep_apart    TEXT NOT NULL,
+				created_by    TEXT NOT NULL,
+				created_at    TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 18,
+		name:    "add follows",
+		statements: []string{
+			`CREATE TABLE follows (
+				follower_id TEXT NOT NULL REFERENCES users (id),
+				followee_id TEXT NOT NULL REFERENCES users (id),
+				created_at  TIMESTAMP NOT NULL,
+				PRIMARY KEY (follower_id, followee_id)
+			)`,
+			`CREATE INDEX idx_follows_followee ON follows (followee_id)`,
+		},
+	},
+	{
+		version: 19,
+		name:    "add clubs",
+		statements: []string{
+			`CREATE TABLE clubs (
+				id          TEXT PRIMARY KEY,
+				name        TEXT NOT NULL,
+				description TEXT NOT NULL,
+				created_at  TIMESTAMP NOT NULL,
+				updated_at  TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE club_members (
+				club_id   TEXT NOT NULL REFERENCES clubs (id),
+				user_id   TEXT NOT NULL REFERENCES users (id),
+				role      TEXT NOT NULL,
+				joined_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (club_id, user_id)
+			)`,
+			`CREATE INDEX idx_club_members_user ON club_members (user_id)`,
+			`CREATE TABLE club_join_requests (
+				club_id    TEXT NOT NULL REFERENCES clubs (id),
+				user_id    TEXT NOT NULL REFERENCES users (id),
+				message    TEXT NOT NULL,
+				status     TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				decided_by TEXT NOT NULL,
+				decided_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (club_id, user_id)
+			)`,
+			`CREATE INDEX idx_club_join_requests_user ON club_join_requests (user_id)`,
+			// Games keep their club ID when the club is deleted, so it is not a foreign key
+			`ALTER TABLE games ADD COLUMN club_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE games ADD COLUMN members_only INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX idx_games_club ON games (club_id)`,
+		},
+	},
+	{
+		version: 20,
+		name:    "add participant sequence",
+		statements: []string{
+			`ALTER TABLE game_participants ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 21,
+		name:    "add club series",
+		statements: []string{
+			`ALTER TABLE game_series ADD COLUMN club_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE game_series ADD COLUMN members_only INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+}
+
+// migrate applies every migration that has not been recorded yet
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("Applied migration %d: %s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// applyMigration runs a migration and records it inside a single transaction
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
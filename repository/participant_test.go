@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"rondo/models"
+)
+
+// backends opens a fresh instance of every storage backend
+func backends(t *testing.T) map[string]*Repositories {
+	t.Helper()
+
+	sqlite, err := OpenSQLite(filepath.Join(t.TempDir(), "rondo.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	return map[string]*Repositories{
+		"memory": NewMemory(),
+		"sqlite": sqlite,
+	}
+}
+
+// createGame stores an upcoming game with the given capacity
+func createGame(t *testing.T, repos *Repositories, id string, capacity int) {
+	t.Helper()
+
+	now := time.Now()
+	game := models.Game{
+		ID:                id,
+		EventName:         "Stress test",
+		StartTime:         now.Add(24 * time.Hour),
+		EndTime:           now.Add(26 * time.Hour),
+		Location:          "Court 1",
+		PlayerRequirement: capacity,
+		CreatorID:         "creator",
+		Status:            models.GameScheduled,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if err := repos.Games.Create(game); err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+}
+
+// countStatuses returns the number of participants in each status
+func countStatuses(t *testing.T, repos *Repositories, gameID string) map[string]int {
+	t.Helper()
+
+	participants, err := repos.Participants.ListByGame(gameID)
+	if err != nil {
+		t.Fatalf("list participants: %v", err)
+	}
+	counts := make(map[string]int)
+	for _, participant := range participants {
+		counts[participant.Status]++
+	}
+	return counts
+}
+
+func TestConcurrentJoinsNeverOverbook(t *testing.T) {
+	const capacity, players = 10, 200
+
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", capacity)
+
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			errs := make(chan error, players)
+			for i := 0; i < players; i++ {
+				wg.Add(1)
+				go func(userID string) {
+					defer wg.Done()
+					<-start
+					if _, err := repos.Participants.Join("game", userID, time.Now()); err != nil {
+						errs <- err
+					}
+				}(fmt.Sprintf("user-%d", i))
+			}
+			close(start)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Errorf("join: %v", err)
+			}
+
+			counts := countStatuses(t, repos, "game")
+			if counts[models.ParticipantJoined] != capacity {
+				t.Errorf("joined = %d, want %d", counts[models.ParticipantJoined], capacity)
+			}
+			if counts[models.ParticipantWaitlisted] != players-capacity {
+				t.Errorf("waitlisted = %d, want %d", counts[models.ParticipantWaitlisted], players-capacity)
+			}
+
+			game, err := repos.Games.Get("game")
+			if err != nil {
+				t.Fatalf("get game: %v", err)
+			}
+			if game.CurrentParticipants != capacity {
+				t.Errorf("current participants = %d, want %d", game.CurrentParticipants, capacity)
+			}
+		})
+	}
+}
+
+func TestConcurrentLeavesAndJoinsNeverOverbook(t *testing.T) {
+	const capacity, players = 5, 100
+
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", capacity)
+
+			// Leaving players free spots that new joins and promotions race for
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < players; i++ {
+				wg.Add(1)
+				go func(userID string) {
+					defer wg.Done()
+					<-start
+
+					participant, err := repos.Participants.Join("game", userID, time.Now())
+					if err != nil {
+						t.Errorf("join: %v", err)
+						return
+					}
+					if participant.Status == models.ParticipantJoined {
+						if err := repos.Participants.Transition("game", userID, models.ParticipantJoined, models.ParticipantLeft, time.Now()); err != nil {
+							t.Errorf("leave: %v", err)
+							return
+						}
+					}
+					if _, err := repos.Participants.PromoteWaitlisted("game", time.Now()); err != nil {
+						t.Errorf("promote: %v", err)
+					}
+				}(fmt.Sprintf("user-%d", i))
+			}
+			close(start)
+			wg.Wait()
+
+			if joined := countStatuses(t, repos, "game")[models.ParticipantJoined]; joined > capacity {
+				t.Errorf("joined = %d, exceeds capacity %d", joined, capacity)
+			}
+		})
+	}
+}
+
+func TestConcurrentLeavesAndPromotionsKeepEveryChange(t *testing.T) {
+	const capacity, players = 3, 60
+
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", capacity)
+			for i := 0; i < capacity; i++ {
+				if _, err := repos.Participants.Join("game", fmt.Sprintf("host-%d", i), time.Now()); err != nil {
+					t.Fatalf("join host: %v", err)
+				}
+			}
+			for i := 0; i < players; i++ {
+				if _, err := repos.Participants.Join("game", fmt.Sprintf("user-%d", i), time.Now()); err != nil {
+					t.Fatalf("join waitlist: %v", err)
+				}
+			}
+
+			// Waitlisted users leave while the hosts' spots are handed out
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			start := make(chan struct{})
+			left := make(map[string]bool)
+			promoted := make(map[string]bool)
+			for i := 0; i < players; i++ {
+				wg.Add(1)
+				go func(userID string) {
+					defer wg.Done()
+					<-start
+					err := repos.Participants.Transition("game", userID, models.ParticipantWaitlisted, models.ParticipantLeft, time.Now())
+					if err != nil && err != ErrNotFound {
+						t.Errorf("leave waitlist: %v", err)
+						return
+					}
+					mu.Lock()
+					left[userID] = err == nil
+					mu.Unlock()
+				}(fmt.Sprintf("user-%d", i))
+			}
+			for i := 0; i < capacity; i++ {
+				wg.Add(1)
+				go func(userID string) {
+					defer wg.Done()
+					<-start
+					if err := repos.Participants.Transition("game", userID, models.ParticipantJoined, models.ParticipantLeft, time.Now()); err != nil {
+						t.Errorf("leave game: %v", err)
+						return
+					}
+					participants, err := repos.Participants.PromoteWaitlisted("game", time.Now())
+					if err != nil {
+						t.Errorf("promote: %v", err)
+						return
+					}
+					mu.Lock()
+					for _, participant := range participants {
+						promoted[participant.UserID] = true
+					}
+					mu.Unlock()
+				}(fmt.Sprintf("host-%d", i))
+			}
+			close(start)
+			wg.Wait()
+
+			// A leave never overwrites a promotion, and a promotion never
+			// brings back a user who left
+			for userID, leftWaitlist := range left {
+				participant, err := repos.Participants.Get("game", userID)
+				if err != nil {
+					t.Fatalf("get %s: %v", userID, err)
+				}
+				switch {
+				case leftWaitlist && (promoted[userID] || participant.Status != models.ParticipantLeft):
+					t.Errorf("%s left the waitlist but is %s (promoted %v)", userID, participant.Status, promoted[userID])
+				case !leftWaitlist && (!promoted[userID] || participant.Status != models.ParticipantJoined):
+					t.Errorf("%s could not leave the waitlist but is %s (promoted %v)", userID, participant.Status, promoted[userID])
+				}
+			}
+			if joined := countStatuses(t, repos, "game")[models.ParticipantJoined]; joined > capacity {
+				t.Errorf("joined = %d, exceeds capacity %d", joined, capacity)
+			}
+		})
+	}
+}
+
+func TestConcurrentLeaveAndRemoveApplyOnce(t *testing.T) {
+	const players = 50
+
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", players)
+			for i := 0; i < players; i++ {
+				if _, err := repos.Participants.Join("game", fmt.Sprintf("user-%d", i), time.Now()); err != nil {
+					t.Fatalf("join: %v", err)
+				}
+			}
+
+			// Every player leaves while an organizer removes them
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			start := make(chan struct{})
+			winners := make(map[string][]string)
+			for i := 0; i < players; i++ {
+				userID := fmt.Sprintf("user-%d", i)
+				for _, status := range []string{models.ParticipantLeft, models.ParticipantRemoved} {
+					wg.Add(1)
+					go func(status string) {
+						defer wg.Done()
+						<-start
+						err := repos.Participants.Transition("game", userID, models.ParticipantJoined, status, time.Now())
+						if err == ErrNotFound {
+							return
+						}
+						if err != nil {
+							t.Errorf("transition to %s: %v", status, err)
+							return
+						}
+						mu.Lock()
+						winners[userID] = append(winners[userID], status)
+						mu.Unlock()
+					}(status)
+				}
+			}
+			close(start)
+			wg.Wait()
+
+			for i := 0; i < players; i++ {
+				userID := fmt.Sprintf("user-%d", i)
+				participant, err := repos.Participants.Get("game", userID)
+				if err != nil {
+					t.Fatalf("get %s: %v", userID, err)
+				}
+				if len(winners[userID]) != 1 || winners[userID][0] != participant.Status {
+					t.Errorf("%s is %s after successful transitions %v, want exactly one", userID, participant.Status, winners[userID])
+				}
+			}
+		})
+	}
+}
+
+func TestJoinRejectsExistingParticipants(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", 1)
+
+			if _, err := repos.Participants.Join("missing", "alice", time.Now()); err != ErrNotFound {
+				t.Errorf("join missing game: err = %v, want ErrNotFound", err)
+			}
+
+			participant, err := repos.Participants.Join("game", "alice", time.Now())
+			if err != nil || participant.Status != models.ParticipantJoined {
+				t.Fatalf("first join: status = %q, err = %v", participant.Status, err)
+			}
+			if _, err := repos.Participants.Join("game", "alice", time.Now()); err != ErrAlreadyExists {
+				t.Errorf("second join: err = %v, want ErrAlreadyExists", err)
+			}
+
+			// A user who left may join again
+			participant.Status = models.ParticipantLeft
+			if err := repos.Participants.Update(participant); err != nil {
+				t.Fatalf("leave: %v", err)
+			}
+			if participant, err = repos.Participants.Join("game", "alice", time.Now()); err != nil || participant.Status != models.ParticipantJoined {
+				t.Errorf("rejoin: status = %q, err = %v", participant.Status, err)
+			}
+		})
+	}
+}
+
+func TestParticipantSequenceCountsChanges(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", 1)
+			sequence := func(userID string) int {
+				t.Helper()
+				participant, err := repos.Participants.Get("game", userID)
+				if err != nil {
+					t.Fatalf("get %s: %v", userID, err)
+				}
+				return participant.Sequence
+			}
+
+			for _, userID := range []string{"alice", "bob"} {
+				if _, err := repos.Participants.Join("game", userID, time.Now()); err != nil {
+					t.Fatalf("join %s: %v", userID, err)
+				}
+			}
+			if err := repos.Participants.Transition("game", "alice", models.ParticipantJoined, models.ParticipantLeft, time.Now()); err != nil {
+				t.Fatalf("leave: %v", err)
+			}
+			if _, err := repos.Participants.PromoteWaitlisted("game", time.Now()); err != nil {
+				t.Fatalf("promote: %v", err)
+			}
+			if got := sequence("alice"); got != 1 {
+				t.Errorf("alice's sequence after leaving = %d, want 1", got)
+			}
+			if got := sequence("bob"); got != 1 {
+				t.Errorf("bob's sequence after promotion = %d, want 1", got)
+			}
+
+			if _, err := repos.Participants.Join("game", "alice", time.Now()); err != nil {
+				t.Fatalf("rejoin: %v", err)
+			}
+			if got := sequence("alice"); got != 2 {
+				t.Errorf("alice's sequence after rejoining = %d, want 2", got)
+			}
+		})
+	}
+}
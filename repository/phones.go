@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// PhoneNormalization reports the outcome of NormalizePhones
+type PhoneNormalization struct {
+	Updated int
+	// Invalid lists users whose phone number could not be parsed
+	Invalid []string
+	// Conflicts lists users whose normalized number belongs to another user
+	Conflicts []string
+}
+
+// NormalizePhones rewrites every user's phone number in the form returned by
+// normalize. Users whose number is invalid or would collide with another
+// user's are left unchanged and reported so they can be merged by hand.
+// Running it again changes nothing.
+func NormalizePhones(users UserRepository, normalize func(string) (string, error)) (PhoneNormalization, error) {
+	var report PhoneNormalization
+
+	all, err := users.List()
+	if err != nil {
+		return report, err
+	}
+	for _, user := range all {
+		phone, err := normalize(user.Phone)
+		if err != nil {
+			report.Invalid = append(report.Invalid, user.ID)
+			continue
+		}
+		if phone == user.Phone {
+			continue
+		}
+
+		user.Phone = phone
+		user.UpdatedAt = time.Now()
+		if err := users.Update(user); err != nil {
+			if errors.Is(err, ErrAlreadyExists) {
+				report.Conflicts = append(report.Conflicts, user.ID)
+				continue
+			}
+			return report, err
+		}
+		report.Updated++
+	}
+	return report, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"rondo/models"
+)
+
+// fixedRate returns a rate function that moves every player's rating by
+// delta and records the ratings it was given
+func fixedRate(gameID string, delta float64, at time.Time, seen *map[string]models.Rating) func(map[string]models.Rating) []models.RatingChange {
+	return func(current map[string]models.Rating) []models.RatingChange {
+		*seen = current
+		var changes []models.RatingChange
+		for _, userID := range []string{"ana", "ben"} {
+			before := 1500.0
+			if rating, exists := current[userID]; exists {
+				before = rating.Rating
+			}
+			changes = append(changes, models.RatingChange{
+				UserID:          userID,
+				GameID:          gameID,
+				Score:           1,
+				RatingBefore:    before,
+				RatingAfter:     before + delta,
+				DeviationBefore: 350,
+				DeviationAfter:  300,
+				VolatilityAfter: 0.06,
+				ChangedAt:       at,
+			})
+		}
+		return changes
+	}
+}
+
+func TestRecordResultUpdatesRatingsOnce(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "first", 10)
+			createGame(t, repos, "second", 10)
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+
+			result := models.GameResult{
+				GameID:      "first",
+				Teams:       [][]string{{"ana"}, {"ben"}},
+				Winner:      0,
+				SubmittedBy: "creator",
+				SubmittedAt: at,
+			}
+			var seen map[string]models.Rating
+			if _, err := repos.Ratings.RecordResult(result, fixedRate("first", 10, at, &seen)); err != nil {
+				t.Fatalf("record first result: %v", err)
+			}
+			if len(seen) != 0 {
+				t.Errorf("unrated players were given ratings %v", seen)
+			}
+
+			stored, err := repos.Ratings.GetResult("first")
+			if err != nil {
+				t.Fatalf("get result: %v", err)
+			}
+			if !reflect.DeepEqual(stored.Teams, result.Teams) || stored.Winner != 0 || !stored.SubmittedAt.Equal(at) {
+				t.Errorf("stored result %+v, want %+v", stored, result)
+			}
+
+			called := false
+			_, err = repos.Ratings.RecordResult(result, func(map[string]models.Rating) []models.RatingChange {
+				called = true
+				return nil
+			})
+			if !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("second result: got %v, want ErrAlreadyExists", err)
+			}
+			if called {
+				t.Error("second result was rated")
+			}
+
+			result.GameID = "second"
+			if _, err := repos.Ratings.RecordResult(result, fixedRate("second", 5, at.Add(time.Hour), &seen)); err != nil {
+				t.Fatalf("record second result: %v", err)
+			}
+			if seen["ana"].Rating != 1510 {
+				t.Errorf("second game was rated from %v, want 1510", seen["ana"].Rating)
+			}
+
+			rating, err := repos.Ratings.Get("ana")
+			if err != nil {
+				t.Fatalf("get rating: %v", err)
+			}
+			if rating.Rating != 1515 || rating.GamesRated != 2 {
+				t.Errorf("rating %v after %d games, want 1515 after 2", rating.Rating, rating.GamesRated)
+			}
+
+			history, err := repos.Ratings.History("ana", 0)
+			if err != nil {
+				t.Fatalf("history: %v", err)
+			}
+			if len(history) != 2 || history[0].GameID != "second" || history[1].GameID != "first" {
+				t.Errorf("history %+v is not newest first", history)
+			}
+			if limited, _ := repos.Ratings.History("ana", 1); len(limited) != 1 {
+				t.Errorf("history limited to 1 returned %d changes", len(limited))
+			}
+
+			changes, err := repos.Ratings.ListByGame("first")
+			if err != nil {
+				t.Fatalf("list by game: %v", err)
+			}
+			if len(changes) != 2 {
+				t.Errorf("game has %d rating changes, want 2", len(changes))
+			}
+		})
+	}
+}
+
+func TestConcurrentResultsAreRecordedOnce(t *testing.T) {
+	const submissions = 20
+
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			createGame(t, repos, "game", 10)
+			at := time.Now()
+			result := models.GameResult{GameID: "game", Teams: [][]string{{"ana"}, {"ben"}}, Winner: -1, SubmittedAt: at}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, submissions)
+			for i := 0; i < submissions; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var seen map[string]models.Rating
+					_, err := repos.Ratings.RecordResult(result, fixedRate("game", 10, at, &seen))
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			recorded := 0
+			for err := range errs {
+				switch {
+				case err == nil:
+					recorded++
+				case !errors.Is(err, ErrAlreadyExists):
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if recorded != 1 {
+				t.Errorf("recorded %d results, want 1", recorded)
+			}
+
+			rating, err := repos.Ratings.Get("ana")
+			if err != nil {
+				t.Fatalf("get rating: %v", err)
+			}
+			if rating.GamesRated != 1 || rating.Rating != 1510 {
+				t.Errorf("rating %v after %d games, want 1510 after 1", rating.Rating, rating.GamesRated)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rondo/config"
+	"rondo/models"
+	"rondo/utils"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrAlreadyExists is returned when a record conflicts with an existing one
+	ErrAlreadyExists = errors.New("record already exists")
+)
+
+// UserRepository stores registered users
+type UserRepository interface {
+	Create(user models.User) error
+	GetByID(id string) (models.User, error)
+	GetByPhone(phone string) (models.User, error)
+	GetByEmail(email string) (models.User, error)
+	Update(user models.User) error
+	// List returns every user, oldest first
+	List() ([]models.User, error)
+	// Search returns the users matching a filter, oldest first
+	Search(filter models.UserFilter) ([]models.User, error)
+	// Delete removes a user together with their sessions, calendar token,
+	// follows and club memberships
+	Delete(id string) error
+}
+
+// GameRepository stores games. CurrentParticipants on returned games is
+// derived from the number of joined participants.
+type GameRepository interface {
+	Create(game models.Game) error
+	Get(id string) (models.Game, error)
+	Update(game models.Game) error
+	// Search returns the games matching a filter in the filter's order
+	Search(filter models.GameFilter) ([]models.Game, error)
+	// ListBySeries returns the games generated for a series, ordered by start time
+	ListBySeries(seriesID string) ([]models.Game, error)
+	// CompleteEnded marks scheduled and confirmed games whose end time is
+	// before now as completed and returns how many were updated
+	CompleteEnded(now time.Time) (int, error)
+	// GetTeams returns the team split stored for a game, or ErrNotFound
+	GetTeams(gameID string) (models.TeamSplit, error)
+	// SaveTeams stores a game's team split, replacing any previous one
+	SaveTeams(split models.TeamSplit) error
+}
+
+// SeriesRepository stores recurring game series
+type SeriesRepository interface {
+	Create(series models.GameSeries) error
+	Get(id string) (models.GameSeries, error)
+	// Update saves a series' template, status and end. GeneratedUntil is left
+	// as stored; only AdvanceGenerated moves it.
+	Update(series models.GameSeries) error
+	// AdvanceGenerated records that games have been generated up to until,
+	// only while the series is still active, and returns the series as
+	// stored afterwards so the caller can see a cancellation that raced it
+	AdvanceGenerated(id string, until, at time.Time) (models.GameSeries, error)
+	ListActive() ([]models.GameSeries, error)
+	// ListByCreator returns the series created by a user, oldest first
+	ListByCreator(creatorID string) ([]models.GameSeries, error)
+}
+
+// ParticipantRepository stores the rosters linking users to games.
+// Each user has at most one participant record per game.
+type ParticipantRepository interface {
+	Add(participant models.Participant) error
+	Get(gameID, userID string) (models.Participant, error)
+	ListByGame(gameID string) ([]models.Participant, error)
+	// ListByUser returns every participant record of a user, including games
+	// they left or were removed from, oldest first
+	ListByUser(userID string) ([]models.Participant, error)
+	Update(participant models.Participant) error
+	// Transition atomically moves a participant from one status to another. It
+	// returns ErrNotFound if the participant does not exist or is no longer in
+	// the from status, so concurrent changes to the same record cannot both apply.
+	Transition(gameID, userID, from, to string, at time.Time) error
+	// Join atomically adds a user to a game's roster if it has a free spot, or
+	// to its waitlist otherwise, and returns the stored participant. A user who
+	// left may join again; any other existing record returns ErrAlreadyExists.
+	// It returns ErrNotFound if the game does not exist.
+	Join(gameID, userID string, at time.Time) (models.Participant, error)
+	// CountPlayed returns how many completed games a user was on the roster of
+	CountPlayed(userID string) (int, error)
+	// PromoteWaitlisted atomically moves waitlisted users onto the roster in
+	// waitlist order until the game is full, returning the promoted participants
+	PromoteWaitlisted(gameID string, at time.Time) ([]models.Participant, error)
+}
+
+// GameHistoryRepository stores the edits made to games
+type GameHistoryRepository interface {
+	Record(changes []models.GameChange) error
+	// ListByGame returns the changes made to a game, oldest first
+	ListByGame(gameID string) ([]models.GameChange, error)
+	// ListByUser returns the changes made by a user to any game, oldest first
+	ListByUser(userID string) ([]models.GameChange, error)
+}
+
+// CalendarTokenRepository stores the calendar feed token of each user.
+// A user has at most one token.
+type CalendarTokenRepository interface {
+	// Save stores a user's token, replacing any previous one
+	Save(token models.CalendarToken) error
+	GetByHash(tokenHash string) (models.CalendarToken, error)
+	GetByUser(userID string) (models.CalendarToken, error)
+	Delete(userID string) error
+}
+
+// SessionRepository stores signed-in devices
+type SessionRepository interface {
+	Create(session models.Session) error
+	Get(id string) (models.Session, error)
+	// ListByUser returns a user's sessions that have not been revoked, most recently used first
+	ListByUser(userID string) ([]models.Session, error)
+	// Rotate atomically replaces the refresh token hash of an active session,
+	// keeping the old one as its previous hash. It returns ErrNotFound if the
+	// session is revoked or currentHash is no longer its token hash.
+	Rotate(id, currentHash, newHash string, expiresAt, at time.Time) error
+	// Touch records that a session was used
+	Touch(id string, at time.Time) error
+	Revoke(id string, at time.Time) error
+	// RevokeAll revokes every active session of a user
+	RevokeAll(userID string, at time.Time) error
+}
+
+// OTPRepository stores pending one-time passwords keyed by phone number, or
+// by another key for codes that are not sign-in codes
+type OTPRepository interface {
+	// Update atomically applies fn to the OTP state of a phone number and
+	// stores the result. fn receives zero data and false if there is none yet.
+	// Nothing is stored if fn returns an error, which Update then returns.
+	Update(phone string, fn func(data *models.OTPData, exists bool) error) error
+	Get(phone string) (models.OTPData, error)
+	Delete(phone string) error
+}
+
+// UsedTokenRepository records redeemed single-use tokens by their ID
+type UsedTokenRepository interface {
+	// Use marks a token as redeemed until it expires, returning
+	// ErrAlreadyExists if it was redeemed before. Records of tokens that
+	// expired before at are pruned.
+	Use(id string, expiresAt, at time.Time) error
+}
+
+// RatingRepository stores player ratings, their history and the game
+// results they were computed from
+type RatingRepository interface {
+	// Get returns a user's rating, or ErrNotFound if they have never been rated
+	Get(userID string) (models.Rating, error)
+	// ListByUsers returns the ratings of the given users keyed by user ID.
+	// Users who have never been rated are left out.
+	ListByUsers(userIDs []string) (map[string]models.Rating, error)
+	// History returns up to limit of a user's rating changes, newest first
+	History(userID string, limit int) ([]models.RatingChange, error)
+	GetResult(gameID string) (models.GameResult, error)
+	// ListByGame returns the rating changes caused by a game's result
+	ListByGame(gameID string) ([]models.RatingChange, error)
+	// RecordResult atomically stores a game's result together with the rating
+	// changes computed by rate, which receives the current ratings of the
+	// result's players as ListByUsers returns them. It returns
+	// ErrAlreadyExists if the game already has a result.
+	RecordResult(result models.GameResult, rate func(current map[string]models.Rating) []models.RatingChange) ([]models.RatingChange, error)
+}
+
+// FollowRepository stores which users follow which
+type FollowRepository interface {
+	// Follow records a follow, or returns ErrAlreadyExists if the follower
+	// already follows the followee
+	Follow(follow models.Follow) error
+	// Unfollow removes a follow, or returns ErrNotFound if there is none
+	Unfollow(followerID, followeeID string) error
+	// ListFollowers returns the follows of a user's followers, newest first
+	ListFollowers(userID string) ([]models.Follow, error)
+	// ListFollowing returns the follows a user made, newest first
+	ListFollowing(userID string) ([]models.Follow, error)
+	// DeleteByUser removes every follow made by or of a user
+	DeleteByUser(userID string) error
+}
+
+// ClubRepository stores clubs, their members and requests to join them
+type ClubRepository interface {
+	// Create stores a new club together with its owner
+	Create(club models.Club, owner models.ClubMember) error
+	Get(id string) (models.Club, error)
+	Update(club models.Club) error
+	// GetMember returns a user's membership of a club, or ErrNotFound
+	GetMember(clubID, userID string) (models.ClubMember, error)
+	// ListMembers returns a club's members in the order they joined
+	ListMembers(clubID string) ([]models.ClubMember, error)
+	// ListByUser returns a user's memberships in the order they joined
+	ListByUser(userID string) ([]models.ClubMember, error)
+	// UpdateMembers changes the roles of several members at once, so that
+	// ownership can be handed over without leaving a club with two owners
+	UpdateMembers(members []models.ClubMember) error
+	// RemoveMember removes a member, or returns ErrNotFound. A club is
+	// deleted together with its last member.
+	RemoveMember(clubID, userID string) error
+	// RequestToJoin stores a pending join request, replacing a decided one,
+	// or returns ErrAlreadyExists if the user already has a pending request
+	RequestToJoin(request models.ClubJoinRequest) error
+	// GetJoinRequest returns a user's request to join a club, or ErrNotFound
+	GetJoinRequest(clubID, userID string) (models.ClubJoinRequest, error)
+	// ListJoinRequests returns a club's requests with a status, oldest first
+	ListJoinRequests(clubID, status string) ([]models.ClubJoinRequest, error)
+	// ListJoinRequestsByUser returns every request a user made, oldest first
+	ListJoinRequestsByUser(userID string) ([]models.ClubJoinRequest, error)
+	// DecideJoinRequest records the decision on a pending request and, if it
+	// was approved, adds the user as a member. It returns ErrNotFound if the
+	// user has no pending request.
+	DecideJoinRequest(request models.ClubJoinRequest) error
+	// DeleteByUser removes a user's memberships and join requests, deleting
+	// the clubs left without members
+	DeleteByUser(userID string) error
+}
+
+// StatsRepository aggregates counts across the other repositories
+type StatsRepository interface {
+	Get(now time.Time) (models.SystemStats, error)
+}
+
+// Repositories groups the repositories used by the handlers
+type Repositories struct {
+	Users        UserRepository
+	Games        GameRepository
+	Series       SeriesRepository
+	Participants ParticipantRepository
+	History      GameHistoryRepository
+	Calendars    CalendarTokenRepository
+	Sessions     SessionRepository
+	OTPs         OTPRepository
+	UsedTokens   UsedTokenRepository
+	Ratings      RatingRepository
+	Follows      FollowRepository
+	Clubs        ClubRepository
+	Stats        StatsRepository
+
+	close func() error
+}
+
+// Close releases the resources held by the backend
+func (r *Repositories) Close() error {
+	if r.close == nil {
+		return nil
+	}
+	return r.close()
+}
+
+// gameGeohash returns the geohash cell indexing a game's coordinates, or an
+// empty string if the game has none
+func gameGeohash(game models.Game) string {
+	if game.Coordinates == nil {
+		return ""
+	}
+	return utils.Geohash(game.Coordinates.Latitude, game.Coordinates.Longitude, utils.GeohashPrecision)
+}
+
+// Open returns the repositories for the configured storage backend
+func Open(cfg config.Config) (*Repositories, error) {
+	switch cfg.StorageBackend {
+	case "memory":
+		return NewMemory(), nil
+	case "sqlite":
+		return OpenSQLite(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"rondo/models"
+)
+
+// createSeries stores an active weekly series starting at the given time
+func createSeries(t *testing.T, repos *Repositories, id string, at time.Time) models.GameSeries {
+	t.Helper()
+
+	series := models.GameSeries{
+		ID:                id,
+		CreatorID:         "creator",
+		RRule:             "FREQ=WEEKLY",
+		Timezone:          "UTC",
+		EventName:         "Weekly 5s",
+		Location:          "Court 1",
+		PlayerRequirement: 10,
+		StartTime:         at,
+		EndTime:           at.Add(time.Hour),
+		Status:            models.SeriesActive,
+		CreatedAt:         at,
+		UpdatedAt:         at,
+	}
+	if err := repos.Series.Create(series); err != nil {
+		t.Fatalf("create series: %v", err)
+	}
+	return series
+}
+
+func TestCancellationDuringGenerationSticks(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+			series := createSeries(t, repos, "series", at)
+
+			// The generator loaded the series before it was cancelled
+			generating := series
+			cancelled := series
+			cancelled.Status = models.SeriesCancelled
+			cancelled.EndsBefore = at.Add(24 * time.Hour)
+			if err := repos.Series.Update(cancelled); err != nil {
+				t.Fatalf("cancel series: %v", err)
+			}
+
+			stored, err := repos.Series.AdvanceGenerated(generating.ID, at.Add(28*24*time.Hour), at.Add(time.Minute))
+			if err != nil {
+				t.Fatalf("advance generated: %v", err)
+			}
+			if stored.Status != models.SeriesCancelled || !stored.EndsBefore.Equal(cancelled.EndsBefore) || !stored.GeneratedUntil.IsZero() {
+				t.Errorf("series after generation is %s ending %v generated until %v, want it cancelled as it was",
+					stored.Status, stored.EndsBefore, stored.GeneratedUntil)
+			}
+			if active, _ := repos.Series.ListActive(); len(active) != 0 {
+				t.Errorf("%d active series after cancellation, want 0", len(active))
+			}
+		})
+	}
+}
+
+func TestUpdateKeepsGenerationProgress(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+			series := createSeries(t, repos, "series", at)
+
+			until := at.Add(28 * 24 * time.Hour)
+			if _, err := repos.Series.AdvanceGenerated(series.ID, until, at); err != nil {
+				t.Fatalf("advance generated: %v", err)
+			}
+
+			// An edit made from a copy loaded before generation does not roll it back
+			series.Location = "Court 2"
+			if err := repos.Series.Update(series); err != nil {
+				t.Fatalf("update series: %v", err)
+			}
+			stored, err := repos.Series.Get(series.ID)
+			if err != nil {
+				t.Fatalf("get series: %v", err)
+			}
+			if stored.Location != "Court 2" || !stored.GeneratedUntil.Equal(until) {
+				t.Errorf("series at %s generated until %v, want Court 2 generated until %v", stored.Location, stored.GeneratedUntil, until)
+			}
+		})
+	}
+}
+
+func TestSeriesKeepsItsClub(t *testing.T) {
+	for name, repos := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			at := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+			series := createSeries(t, repos, "series", at)
+			series.ClubID = "club"
+			series.ID = "club-series"
+			series.MembersOnly = true
+			if err := repos.Series.Create(series); err != nil {
+				t.Fatalf("create club series: %v", err)
+			}
+
+			// Members-only can be switched off, but the series stays with its club
+			series.MembersOnly = false
+			if err := repos.Series.Update(series); err != nil {
+				t.Fatalf("update series: %v", err)
+			}
+			stored, err := repos.Series.Get(series.ID)
+			if err != nil {
+				t.Fatalf("get series: %v", err)
+			}
+			if stored.ClubID != "club" || stored.MembersOnly {
+				t.Errorf("series has club %q, members only %t, want club and not members only", stored.ClubID, stored.MembersOnly)
+			}
+		})
+	}
+}
@@ -0,0 +1,1550 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"rondo/models"
+)
+
+// OpenSQLite opens (or creates) the SQLite database at path, applies any
+// pending schema migrations and returns repositories backed by it.
+//
+// Times are always written in UTC: SQLite compares timestamps as text, so
+// mixing offsets would break ordering and range queries.
+func OpenSQLite(path string) (*Repositories, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer; serializing connections avoids
+	// "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Repositories{
+		Users:        &sqliteUserRepository{db: db},
+		Games:        &sqliteGameRepository{db: db},
+		Series:       &sqliteSeriesRepository{db: db},
+		Participants: &sqliteParticipantRepository{db: db},
+		History:      &sqliteGameHistoryRepository{db: db},
+		Calendars:    &sqliteCalendarTokenRepository{db: db},
+		Sessions:     &sqliteSessionRepository{db: db},
+		OTPs:         &sqliteOTPRepository{db: db},
+		UsedTokens:   &sqliteUsedTokenRepository{db: db},
+		Ratings:      &sqliteRatingRepository{db: db},
+		Follows:      &sqliteFollowRepository{db: db},
+		Clubs:        &sqliteClubRepository{db: db},
+		Stats:        &sqliteStatsRepository{db: db},
+		close:        db.Close,
+	}, nil
+}
+
+// isConstraintViolation reports whether err is a SQLite unique or primary key violation
+func isConstraintViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
+
+// isForeignKeyViolation reports whether err is a SQLite foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// checkAffected converts an update that touched no rows into ErrNotFound
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// sqliteUserRepository is a UserRepository backed by the users table
+type sqliteUserRepository struct {
+	db *sql.DB
+}
+
+const userColumns = `id, first_name, last_name, dob, phone, email, display_name, show_age_band, show_games_played, position, role,
+	suspended_at, suspended_reason, deleted_at, created_at, updated_at`
+
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.DOB, &user.Phone, &user.Email,
+		&user.DisplayName, &user.Visibility.AgeBand, &user.Visibility.GamesPlayed, &user.Position, &user.Role,
+		&user.SuspendedAt, &user.SuspendedReason, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrNotFound
+	}
+	return user, err
+}
+
+func (r *sqliteUserRepository) Create(user models.User) error {
+	_, err := r.db.Exec(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.FirstName, user.LastName, user.DOB.UTC(), user.Phone, user.Email,
+		user.DisplayName, user.Visibility.AgeBand, user.Visibility.GamesPlayed, user.Position, user.Role,
+		user.SuspendedAt.UTC(), user.SuspendedReason, user.DeletedAt.UTC(), user.CreatedAt.UTC(), user.UpdatedAt.UTC())
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *sqliteUserRepository) GetByID(id string) (models.User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (r *sqliteUserRepository) GetByPhone(phone string) (models.User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE phone = ?`, phone))
+}
+
+func (r *sqliteUserRepository) GetByEmail(email string) (models.User, error) {
+	if email == "" {
+		return models.User{}, ErrNotFound
+	}
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+}
+
+func (r *sqliteUserRepository) Update(user models.User) error {
+	result, err := r.db.Exec(`UPDATE users SET first_name = ?, last_name = ?, dob = ?, phone = ?, email = ?,
+		display_name = ?, show_age_band = ?, show_games_played = ?, position = ?, role = ?, suspended_at = ?,
+		suspended_reason = ?, deleted_at = ?, updated_at = ? WHERE id = ?`,
+		user.FirstName, user.LastName, user.DOB.UTC(), user.Phone, user.Email,
+		user.DisplayName, user.Visibility.AgeBand, user.Visibility.GamesPlayed, user.Position, user.Role,
+		user.SuspendedAt.UTC(), user.SuspendedReason, user.DeletedAt.UTC(), user.UpdatedAt.UTC(), user.ID)
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteUserRepository) Delete(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Sessions, calendar tokens, follows and club memberships reference the
+	// user, so they go first
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM calendar_tokens WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM follows WHERE follower_id = ? OR followee_id = ?`, id, id); err != nil {
+		return err
+	}
+	if err := deleteClubMemberships(tx, id); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteUserRepository) List() ([]models.User, error) {
+	return r.query(`SELECT ` + userColumns + ` FROM users ORDER BY created_at, id`)
+}
+
+func (r *sqliteUserRepository) Search(filter models.UserFilter) ([]models.User, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Query != "" {
+		conditions = append(conditions, `(LOWER(first_name || ' ' || last_name) LIKE ? ESCAPE '\' OR phone LIKE ? ESCAPE '\')`)
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, `role = ?`)
+		args = append(args, filter.Role)
+	}
+	if filter.Suspended != nil {
+		if *filter.Suspended {
+			conditions = append(conditions, `suspended_at != ?`)
+		} else {
+			conditions = append(conditions, `suspended_at = ?`)
+		}
+		args = append(args, time.Time{})
+	}
+	if filter.After != nil {
+		conditions = append(conditions, `(created_at > ? OR (created_at = ? AND id > ?))`)
+		args = append(args, filter.After.CreatedAt.UTC(), filter.After.CreatedAt.UTC(), filter.After.ID)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY created_at, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	return r.query(query, args...)
+}
+
+// query runs a user select and scans every row
+func (r *sqliteUserRepository) query(query string, args ...any) ([]models.User, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// sqliteGameRepository is a GameRepository backed by the games table
+type sqliteGameRepository struct {
+	db *sql.DB
+}
+
+const gameColumns = `id, event_name, start_time, end_time, location, cost_per_person,
+	player_requirement, creator_id, status, cancel_reason, series_id, recurrence_id, created_at, updated_at,
+	latitude, longitude, sequence, club_id, members_only`
+
+// joinedCountSelect counts the joined participants of the game in the current row
+const joinedCountSelect = `(SELECT COUNT(*) FROM game_participants p WHERE p.game_id = games.id AND p.status = 'joined')`
+
+// gameSelect reads games together with their derived participant count
+const gameSelect = `SELECT ` + gameColumns + `, ` + joinedCountSelect + ` FROM games`
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func scanGame(row scanner) (models.Game, error) {
+	var game models.Game
+	var latitude, longitude sql.NullFloat64
+	err := row.Scan(&game.ID, &game.EventName, &game.StartTime, &game.EndTime, &game.Location, &game.CostPerPerson,
+		&game.PlayerRequirement, &game.CreatorID, &game.Status, &game.CancelReason, &game.SeriesID, &game.RecurrenceID,
+		&game.CreatedAt, &game.UpdatedAt, &latitude, &longitude, &game.Sequence, &game.ClubID, &game.MembersOnly,
+		&game.CurrentParticipants)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Game{}, ErrNotFound
+	}
+	game.Coordinates = scanCoordinates(latitude, longitude)
+	return game, err
+}
+
+// coordinateArgs converts optional coordinates into nullable column values
+func coordinateArgs(point *models.GeoPoint) (sql.NullFloat64, sql.NullFloat64) {
+	if point == nil {
+		return sql.NullFloat64{}, sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: point.Latitude, Valid: true}, sql.NullFloat64{Float64: point.Longitude, Valid: true}
+}
+
+// scanCoordinates converts nullable columns back into optional coordinates
+func scanCoordinates(latitude, longitude sql.NullFloat64) *models.GeoPoint {
+	if !latitude.Valid || !longitude.Valid {
+		return nil
+	}
+	return &models.GeoPoint{Latitude: latitude.Float64, Longitude: longitude.Float64}
+}
+
+func (r *sqliteGameRepository) Create(game models.Game) error {
+	latitude, longitude := coordinateArgs(game.Coordinates)
+	_, err := r.db.Exec(`INSERT INTO games (`+gameColumns+`, geohash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		game.ID, game.EventName, game.StartTime.UTC(), game.EndTime.UTC(), game.Location, game.CostPerPerson,
+		game.PlayerRequirement, game.CreatorID, game.Status, game.CancelReason, game.SeriesID, game.RecurrenceID.UTC(),
+		game.CreatedAt.UTC(), game.UpdatedAt.UTC(), latitude, longitude, game.Sequence, game.ClubID, game.MembersOnly,
+		gameGeohash(game))
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *sqliteGameRepository) Get(id string) (models.Game, error) {
+	return scanGame(r.db.QueryRow(gameSelect+` WHERE id = ?`, id))
+}
+
+func (r *sqliteGameRepository) Update(game models.Game) error {
+	latitude, longitude := coordinateArgs(game.Coordinates)
+	result, err := r.db.Exec(`UPDATE games SET event_name = ?, start_time = ?, end_time = ?, location = ?,
+		latitude = ?, longitude = ?, geohash = ?, cost_per_person = ?, player_requirement = ?, status = ?,
+		cancel_reason = ?, members_only = ?, sequence = ?, updated_at = ? WHERE id = ?`,
+		game.EventName, game.StartTime.UTC(), game.EndTime.UTC(), game.Location, latitude, longitude, gameGeohash(game),
+		game.CostPerPerson, game.PlayerRequirement, game.Status, game.CancelReason, game.MembersOnly, game.Sequence,
+		game.UpdatedAt.UTC(), game.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteGameRepository) Search(filter models.GameFilter) ([]models.Game, error) {
+	var conditions []string
+	var args []any
+
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, `status IN (?`+strings.Repeat(`, ?`, len(filter.Statuses)-1)+`)`)
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if !filter.StartAfter.IsZero() {
+		conditions = append(conditions, `start_time >= ?`)
+		args = append(args, filter.StartAfter.UTC())
+	}
+	if !filter.StartBefore.IsZero() {
+		conditions = append(conditions, `start_time < ?`)
+		args = append(args, filter.StartBefore.UTC())
+	}
+	if filter.Location != "" {
+		conditions = append(conditions, `LOWER(location) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(filter.Location))+"%")
+	}
+	if filter.MaxCost != nil {
+		conditions = append(conditions, `cost_per_person <= ?`)
+		args = append(args, *filter.MaxCost)
+	}
+	if filter.HasOpenSpots {
+		conditions = append(conditions, `player_requirement > `+joinedCountSelect)
+	}
+	if filter.CreatorID != "" {
+		conditions = append(conditions, `creator_id = ?`)
+		args = append(args, filter.CreatorID)
+	}
+	if filter.MemberID != "" {
+		conditions = append(conditions, `(creator_id = ? OR EXISTS (SELECT 1 FROM game_participants p
+			WHERE p.game_id = games.id AND p.user_id = ? AND p.status = 'joined'))`)
+		args = append(args, filter.MemberID, filter.MemberID)
+	}
+	if filter.FollowedBy != "" {
+		conditions = append(conditions, `creator_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)`)
+		args = append(args, filter.FollowedBy)
+	}
+	if filter.ClubID != "" {
+		conditions = append(conditions, `club_id = ?`)
+		args = append(args, filter.ClubID)
+	}
+	if filter.PublicOnly {
+		conditions = append(conditions, `members_only = 0`)
+	} else if filter.VisibleTo != "" {
+		conditions = append(conditions, `(members_only = 0 OR creator_id = ?
+			OR EXISTS (SELECT 1 FROM club_members m WHERE m.club_id = games.club_id AND m.user_id = ?)
+			OR EXISTS (SELECT 1 FROM game_participants p
+				WHERE p.game_id = games.id AND p.user_id = ? AND p.status IN ('joined', 'waitlisted')))`)
+		args = append(args, filter.VisibleTo, filter.VisibleTo, filter.VisibleTo)
+	}
+	if len(filter.GeohashPrefixes) > 0 {
+		// Prefix matches as ranges so they can use the geohash index
+		cells := make([]string, len(filter.GeohashPrefixes))
+		for i, prefix := range filter.GeohashPrefixes {
+			cells[i] = `(geohash >= ? AND geohash < ?)`
+			args = append(args, prefix, prefix+"~")
+		}
+		conditions = append(conditions, `(`+strings.Join(cells, ` OR `)+`)`)
+	}
+
+	column, direction, comparison := "start_time", "ASC", ">"
+	if filter.SortBy == models.SortByCost {
+		column = "cost_per_person"
+	}
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		var value any = filter.After.StartTime.UTC()
+		if filter.SortBy == models.SortByCost {
+			value = filter.After.Cost
+		}
+		conditions = append(conditions, fmt.Sprintf(`(%s %s ? OR (%s = ? AND id %s ?))`, column, comparison, column, comparison))
+		args = append(args, value, value, filter.After.ID)
+	}
+
+	query := gameSelect
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, id %s`, column, direction, direction)
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	return r.query(query, args...)
+}
+
+func (r *sqliteGameRepository) ListBySeries(seriesID string) ([]models.Game, error) {
+	return r.query(gameSelect+` WHERE series_id = ? ORDER BY start_time`, seriesID)
+}
+
+// query runs a game select and scans every row
+func (r *sqliteGameRepository) query(query string, args ...any) ([]models.Game, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []models.Game
+	for rows.Next() {
+		game, err := scanGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, game)
+	}
+	return games, rows.Err()
+}
+
+func (r *sqliteGameRepository) CompleteEnded(now time.Time) (int, error) {
+	result, err := r.db.Exec(`UPDATE games SET status = 'completed', updated_at = ?, sequence = sequence + 1
+		WHERE status IN ('scheduled', 'confirmed') AND end_time < ?`, now.UTC(), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	completed, err := result.RowsAffected()
+	return int(completed), err
+}
+
+func (r *sqliteGameRepository) GetTeams(gameID string) (models.TeamSplit, error) {
+	split := models.TeamSplit{GameID: gameID}
+	var teams, keepTogether, keepApart string
+	err := r.db.QueryRow(`SELECT teams, keep_together, keep_apart, created_by, created_at FROM game_teams
+		WHERE game_id = ?`, gameID).Scan(&teams, &keepTogether, &keepApart, &split.CreatedBy, &split.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TeamSplit{}, ErrNotFound
+	}
+	if err != nil {
+		return models.TeamSplit{}, err
+	}
+
+	for _, column := range []struct {
+		value  string
+		target *[][]string
+	}{
+		{teams, &split.Teams},
+		{keepTogether, &split.KeepTogether},
+		{keepApart, &split.KeepApart},
+	} {
+		if err := json.Unmarshal([]byte(column.value), column.target); err != nil {
+			return models.TeamSplit{}, fmt.Errorf("decode teams of game %s: %w", gameID, err)
+		}
+	}
+	return split, nil
+}
+
+func (r *sqliteGameRepository) SaveTeams(split models.TeamSplit) error {
+	var columns [3][]byte
+	for i, value := range [][][]string{split.Teams, split.KeepTogether, split.KeepApart} {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		columns[i] = encoded
+	}
+
+	_, err := r.db.Exec(`INSERT INTO game_teams (game_id, teams, keep_together, keep_apart, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (game_id) DO UPDATE SET teams = excluded.teams, keep_together = excluded.keep_together,
+			keep_apart = excluded.keep_apart, created_by = excluded.created_by, created_at = excluded.created_at`,
+		split.GameID, string(columns[0]), string(columns[1]), string(columns[2]), split.CreatedBy, split.CreatedAt.UTC())
+	if isForeignKeyViolation(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// sqliteSeriesRepository is a SeriesRepository backed by the game_series table
+type sqliteSeriesRepository struct {
+	db *sql.DB
+}
+
+const seriesColumns = `id, creator_id, rrule, timezone, event_name, location, cost_per_person, player_requirement,
+	start_time, end_time, status, ends_before, generated_until, created_at, updated_at, latitude, longitude, club_id, members_only`
+
+func scanSeries(row scanner) (models.GameSeries, error) {
+	var series models.GameSeries
+	var latitude, longitude sql.NullFloat64
+	err := row.Scan(&series.ID, &series.CreatorID, &series.RRule, &series.Timezone, &series.EventName, &series.Location,
+		&series.CostPerPerson, &series.PlayerRequirement, &series.StartTime, &series.EndTime, &series.Status,
+		&series.EndsBefore, &series.GeneratedUntil, &series.CreatedAt, &series.UpdatedAt, &latitude, &longitude,
+		&series.ClubID, &series.MembersOnly)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.GameSeries{}, ErrNotFound
+	}
+	series.Coordinates = scanCoordinates(latitude, longitude)
+	return series, err
+}
+
+func (r *sqliteSeriesRepository) Create(series models.GameSeries) error {
+	latitude, longitude := coordinateArgs(series.Coordinates)
+	_, err := r.db.Exec(`INSERT INTO game_series (`+seriesColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		series.ID, series.CreatorID, series.RRule, series.Timezone, series.EventName, series.Location,
+		series.CostPerPerson, series.PlayerRequirement, series.StartTime.UTC(), series.EndTime.UTC(), series.Status,
+		series.EndsBefore.UTC(), series.GeneratedUntil.UTC(), series.CreatedAt.UTC(), series.UpdatedAt.UTC(),
+		latitude, longitude, series.ClubID, series.MembersOnly)
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *sqliteSeriesRepository) Get(id string) (models.GameSeries, error) {
+	return scanSeries(r.db.QueryRow(`SELECT `+seriesColumns+` FROM game_series WHERE id = ?`, id))
+}
+
+func (r *sqliteSeriesRepository) Update(series models.GameSeries) error {
+	latitude, longitude := coordinateArgs(series.Coordinates)
+	result, err := r.db.Exec(`UPDATE game_series SET event_name = ?, location = ?, latitude = ?, longitude = ?,
+		cost_per_person = ?, player_requirement = ?, members_only = ?, status = ?, ends_before = ?, updated_at = ?
+		WHERE id = ?`,
+		series.EventName, series.Location, latitude, longitude, series.CostPerPerson, series.PlayerRequirement,
+		series.MembersOnly, series.Status, series.EndsBefore.UTC(), series.UpdatedAt.UTC(), series.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteSeriesRepository) AdvanceGenerated(id string, until, at time.Time) (models.GameSeries, error) {
+	if _, err := r.db.Exec(`UPDATE game_series SET generated_until = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		until.UTC(), at.UTC(), id, models.SeriesActive); err != nil {
+		return models.GameSeries{}, err
+	}
+	return r.Get(id)
+}
+
+func (r *sqliteSeriesRepository) ListActive() ([]models.GameSeries, error) {
+	return r.query(`SELECT `+seriesColumns+` FROM game_series WHERE status = ?`, models.SeriesActive)
+}
+
+func (r *sqliteSeriesRepository) ListByCreator(creatorID string) ([]models.GameSeries, error) {
+	return r.query(`SELECT `+seriesColumns+` FROM game_series WHERE creator_id = ? ORDER BY created_at, id`, creatorID)
+}
+
+// query runs a series select and scans every row
+func (r *sqliteSeriesRepository) query(query string, args ...any) ([]models.GameSeries, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.GameSeries
+	for rows.Next() {
+		series, err := scanSeries(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, series)
+	}
+	return list, rows.Err()
+}
+
+// sqliteParticipantRepository is a ParticipantRepository backed by the game_participants table
+type sqliteParticipantRepository struct {
+	db *sql.DB
+}
+
+const participantColumns = `game_id, user_id, status, joined_at, updated_at, sequence`
+
+func scanParticipant(row scanner) (models.Participant, error) {
+	var participant models.Participant
+	err := row.Scan(&participant.GameID, &participant.UserID, &participant.Status, &participant.JoinedAt, &participant.UpdatedAt,
+		&participant.Sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Participant{}, ErrNotFound
+	}
+	return participant, err
+}
+
+func (r *sqliteParticipantRepository) Add(participant models.Participant) error {
+	_, err := r.db.Exec(`INSERT INTO game_participants (`+participantColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		participant.GameID, participant.UserID, participant.Status, participant.JoinedAt.UTC(), participant.UpdatedAt.UTC(),
+		participant.Sequence)
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *sqliteParticipantRepository) Get(gameID, userID string) (models.Participant, error) {
+	return scanParticipant(r.db.QueryRow(`SELECT `+participantColumns+` FROM game_participants
+		WHERE game_id = ? AND user_id = ?`, gameID, userID))
+}
+
+func (r *sqliteParticipantRepository) ListByGame(gameID string) ([]models.Participant, error) {
+	return r.query(`SELECT `+participantColumns+` FROM game_participants
+		WHERE game_id = ? ORDER BY joined_at`, gameID)
+}
+
+func (r *sqliteParticipantRepository) ListByUser(userID string) ([]models.Participant, error) {
+	return r.query(`SELECT `+participantColumns+` FROM game_participants
+		WHERE user_id = ? ORDER BY joined_at`, userID)
+}
+
+// query runs a participant select and scans every row
+func (r *sqliteParticipantRepository) query(query string, args ...any) ([]models.Participant, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []models.Participant
+	for rows.Next() {
+		participant, err := scanParticipant(rows)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	return participants, rows.Err()
+}
+
+func (r *sqliteParticipantRepository) Update(participant models.Participant) error {
+	result, err := r.db.Exec(`UPDATE game_participants SET status = ?, joined_at = ?, updated_at = ?, sequence = ?
+		WHERE game_id = ? AND user_id = ?`,
+		participant.Status, participant.JoinedAt.UTC(), participant.UpdatedAt.UTC(), participant.Sequence,
+		participant.GameID, participant.UserID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteParticipantRepository) Transition(gameID, userID, from, to string, at time.Time) error {
+	result, err := r.db.Exec(`UPDATE game_participants SET status = ?, updated_at = ?, sequence = sequence + 1
+		WHERE game_id = ? AND user_id = ? AND status = ?`,
+		to, at.UTC(), gameID, userID, from)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteParticipantRepository) Join(gameID, userID string, at time.Time) (models.Participant, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Participant{}, err
+	}
+	defer tx.Rollback()
+
+	// The capacity check and the insert are a single statement, so concurrent
+	// joins cannot both take the last spot
+	result, err := tx.Exec(`INSERT INTO game_participants (`+participantColumns+`)
+		SELECT ?, ?, CASE WHEN `+joinedCountSelect+` < player_requirement THEN 'joined' ELSE 'waitlisted' END, ?, ?, 0
+		FROM games WHERE id = ?
+		ON CONFLICT (game_id, user_id) DO UPDATE SET status = excluded.status, joined_at = excluded.joined_at,
+			updated_at = excluded.updated_at, sequence = game_participants.sequence + 1
+		WHERE game_participants.status = 'left'`,
+		gameID, userID, at.UTC(), at.UTC(), gameID)
+	if err != nil {
+		return models.Participant{}, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return models.Participant{}, err
+	} else if affected == 0 {
+		// Either the game is missing or the user already has an active record
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM games WHERE id = ?)`, gameID).Scan(&exists); err != nil {
+			return models.Participant{}, err
+		}
+		if !exists {
+			return models.Participant{}, ErrNotFound
+		}
+		return models.Participant{}, ErrAlreadyExists
+	}
+
+	participant, err := scanParticipant(tx.QueryRow(`SELECT `+participantColumns+` FROM game_participants
+		WHERE game_id = ? AND user_id = ?`, gameID, userID))
+	if err != nil {
+		return models.Participant{}, err
+	}
+	return participant, tx.Commit()
+}
+
+func (r *sqliteParticipantRepository) CountPlayed(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM game_participants p JOIN games g ON g.id = p.game_id
+		WHERE p.user_id = ? AND p.status = ? AND g.status = ?`,
+		userID, models.ParticipantJoined, models.GameCompleted).Scan(&count)
+	return count, err
+}
+
+func (r *sqliteParticipantRepository) PromoteWaitlisted(gameID string, at time.Time) ([]models.Participant, error) {
+	var exists bool
+	if err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM games WHERE id = ?)`, gameID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	// Free spots are counted in the same statement that fills them
+	rows, err := r.db.Query(`UPDATE game_participants SET status = 'joined', updated_at = ?, sequence = sequence + 1
+		WHERE game_id = ? AND user_id IN (
+			SELECT user_id FROM game_participants WHERE game_id = ? AND status = 'waitlisted'
+			ORDER BY joined_at
+			LIMIT MAX(0, (SELECT player_requirement FROM games WHERE id = ?) -
+				(SELECT COUNT(*) FROM game_participants WHERE game_id = ? AND status = 'joined')))
+		RETURNING `+participantColumns,
+		at.UTC(), gameID, gameID, gameID, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promoted []models.Participant
+	for rows.Next() {
+		participant, err := scanParticipant(rows)
+		if err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, participant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not preserve the waitlist order
+	sort.Slice(promoted, func(i, j int) bool {
+		return promoted[i].JoinedAt.Before(promoted[j].JoinedAt)
+	})
+	return promoted, nil
+}
+
+// sqliteGameHistoryRepository is a GameHistoryRepository backed by the game_changes table
+type sqliteGameHistoryRepository struct {
+	db *sql.DB
+}
+
+func (r *sqliteGameHistoryRepository) Record(changes []models.GameChange) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		if _, err := tx.Exec(`INSERT INTO game_changes (game_id, field, old_value, new_value, changed_by, changed_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			change.GameID, change.Field, change.OldValue, change.NewValue, change.ChangedBy, change.ChangedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteGameHistoryRepository) ListByGame(gameID string) ([]models.GameChange, error) {
+	return r.query(`SELECT game_id, field, old_value, new_value, changed_by, changed_at
+		FROM game_changes WHERE game_id = ? ORDER BY id`, gameID)
+}
+
+func (r *sqliteGameHistoryRepository) ListByUser(userID string) ([]models.GameChange, error) {
+	return r.query(`SELECT game_id, field, old_value, new_value, changed_by, changed_at
+		FROM game_changes WHERE changed_by = ? ORDER BY changed_at, id`, userID)
+}
+
+// query runs a game change select and scans every row
+func (r *sqliteGameHistoryRepository) query(query string, args ...any) ([]models.GameChange, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.GameChange
+	for rows.Next() {
+		var change models.GameChange
+		if err := rows.Scan(&change.GameID, &change.Field, &change.OldValue, &change.NewValue,
+			&change.ChangedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// sqliteCalendarTokenRepository is a CalendarTokenRepository backed by the calendar_tokens table
+type sqliteCalendarTokenRepository struct {
+	db *sql.DB
+}
+
+func (r *sqliteCalendarTokenRepository) Save(token models.CalendarToken) error {
+	_, err := r.db.Exec(`INSERT INTO calendar_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		token.UserID, token.TokenHash, token.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqliteCalendarTokenRepository) GetByHash(tokenHash string) (models.CalendarToken, error) {
+	return scanCalendarToken(r.db.QueryRow(`SELECT user_id, token_hash, created_at FROM calendar_tokens
+		WHERE token_hash = ?`, tokenHash))
+}
+
+func (r *sqliteCalendarTokenRepository) GetByUser(userID string) (models.CalendarToken, error) {
+	return scanCalendarToken(r.db.QueryRow(`SELECT user_id, token_hash, created_at FROM calendar_tokens
+		WHERE user_id = ?`, userID))
+}
+
+func scanCalendarToken(row scanner) (models.CalendarToken, error) {
+	var token models.CalendarToken
+	err := row.Scan(&token.UserID, &token.TokenHash, &token.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.CalendarToken{}, ErrNotFound
+	}
+	return token, err
+}
+
+func (r *sqliteCalendarTokenRepository) Delete(userID string) error {
+	_, err := r.db.Exec(`DELETE FROM calendar_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+// sqliteSessionRepository is a SessionRepository backed by the sessions table
+type sqliteSessionRepository struct {
+	db *sql.DB
+}
+
+const sessionColumns = `id, user_id, token_hash, previous_token_hash, user_agent, ip_address,
+	created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row scanner) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.TokenHash, &session.PreviousTokenHash, &session.UserAgent,
+		&session.IPAddress, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, ErrNotFound
+	}
+	return session, err
+}
+
+func (r *sqliteSessionRepository) Create(session models.Session) error {
+	_, err := r.db.Exec(`INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.TokenHash, session.PreviousTokenHash, session.UserAgent, session.IPAddress,
+		session.CreatedAt.UTC(), session.LastUsedAt.UTC(), session.ExpiresAt.UTC(), session.RevokedAt.UTC())
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *sqliteSessionRepository) Get(id string) (models.Session, error) {
+	return scanSession(r.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+}
+
+func (r *sqliteSessionRepository) ListByUser(userID string) ([]models.Session, error) {
+	rows, err := r.db.Query(`SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND revoked_at = ? ORDER BY last_used_at DESC`, userID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *sqliteSessionRepository) Rotate(id, currentHash, newHash string, expiresAt, at time.Time) error {
+	result, err := r.db.Exec(`UPDATE sessions SET previous_token_hash = token_hash, token_hash = ?,
+		expires_at = ?, last_used_at = ? WHERE id = ? AND token_hash = ? AND revoked_at = ?`,
+		newHash, expiresAt.UTC(), at.UTC(), id, currentHash, time.Time{})
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteSessionRepository) Touch(id string, at time.Time) error {
+	result, err := r.db.Exec(`UPDATE sessions SET last_used_at = ? WHERE id = ?`, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteSessionRepository) RevokeAll(userID string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at = ?`, at.UTC(), userID, time.Time{})
+	return err
+}
+
+func (r *sqliteSessionRepository) Revoke(id string, at time.Time) error {
+	if _, err := r.Get(id); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at = ?`, at.UTC(), id, time.Time{})
+	return err
+}
+
+// sqliteOTPRepository is an OTPRepository backed by the otps table
+type sqliteOTPRepository struct {
+	db *sql.DB
+}
+
+const otpColumns = `code_hash, salt, created_at, attempts, lockouts, locked_until, target`
+
+func scanOTP(row scanner) (models.OTPData, error) {
+	var data models.OTPData
+	err := row.Scan(&data.CodeHash, &data.Salt, &data.CreatedAt, &data.Attempts, &data.Lockouts, &data.LockedUntil, &data.Target)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OTPData{}, ErrNotFound
+	}
+	return data, err
+}
+
+func (r *sqliteOTPRepository) Update(phone string, fn func(data *models.OTPData, exists bool) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	data, err := scanOTP(tx.QueryRow(`SELECT `+otpColumns+` FROM otps WHERE phone = ?`, phone))
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err := fn(&data, exists); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO otps (phone, `+otpColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (phone) DO UPDATE SET code_hash = excluded.code_hash, salt = excluded.salt,
+			created_at = excluded.created_at, attempts = excluded.attempts, lockouts = excluded.lockouts,
+			locked_until = excluded.locked_until, target = excluded.target`,
+		phone, data.CodeHash, data.Salt, data.CreatedAt.UTC(), data.Attempts, data.Lockouts, data.LockedUntil.UTC(), data.Target)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqliteOTPRepository) Get(phone string) (models.OTPData, error) {
+	return scanOTP(r.db.QueryRow(`SELECT `+otpColumns+` FROM otps WHERE phone = ?`, phone))
+}
+
+func (r *sqliteOTPRepository) Delete(phone string) error {
+	_, err := r.db.Exec(`DELETE FROM otps WHERE phone = ?`, phone)
+	return err
+}
+
+// sqliteUsedTokenRepository is a UsedTokenRepository backed by the used_tokens table
+type sqliteUsedTokenRepository struct {
+	db *sql.DB
+}
+
+func (r *sqliteUsedTokenRepository) Use(id string, expiresAt, at time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM used_tokens WHERE expires_at < ?`, at.UTC()); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`INSERT INTO used_tokens (id, expires_at) VALUES (?, ?)`, id, expiresAt.UTC())
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// sqliteRatingRepository is a RatingRepository backed by the ratings,
+// game_results and rating_changes tables
+type sqliteRatingRepository struct {
+	db *sql.DB
+}
+
+const ratingColumns = `user_id, rating, deviation, volatility, games_rated, updated_at`
+
+func scanRating(row scanner) (models.Rating, error) {
+	var rating models.Rating
+	err := row.Scan(&rating.UserID, &rating.Rating, &rating.Deviation, &rating.Volatility, &rating.GamesRated, &rating.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Rating{}, ErrNotFound
+	}
+	return rating, err
+}
+
+const ratingChangeColumns = `user_id, game_id, score, rating_before, rating_after, deviation_before, deviation_after,
+	volatility_after, changed_at`
+
+func (r *sqliteRatingRepository) Get(userID string) (models.Rating, error) {
+	return scanRating(r.db.QueryRow(`SELECT `+ratingColumns+` FROM ratings WHERE user_id = ?`, userID))
+}
+
+func (r *sqliteRatingRepository) ListByUsers(userIDs []string) (map[string]models.Rating, error) {
+	return listRatings(r.db, userIDs)
+}
+
+// listRatings loads the stored ratings of the given users with db, which may
+// be a transaction
+func listRatings(db interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, userIDs []string) (map[string]models.Rating, error) {
+	ratings := make(map[string]models.Rating)
+	if len(userIDs) == 0 {
+		return ratings, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userIDs)), ", ")
+	args := make([]any, len(userIDs))
+	for i, userID := range userIDs {
+		args[i] = userID
+	}
+	rows, err := db.Query(`SELECT `+ratingColumns+` FROM ratings WHERE user_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rating, err := scanRating(rows)
+		if err != nil {
+			return nil, err
+		}
+		ratings[rating.UserID] = rating
+	}
+	return ratings, rows.Err()
+}
+
+func (r *sqliteRatingRepository) History(userID string, limit int) ([]models.RatingChange, error) {
+	// A negative LIMIT means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+	return r.queryChanges(`SELECT `+ratingChangeColumns+` FROM rating_changes
+		WHERE user_id = ? ORDER BY id DESC LIMIT ?`, userID, limit)
+}
+
+func (r *sqliteRatingRepository) GetResult(gameID string) (models.GameResult, error) {
+	var result models.GameResult
+	var teams string
+	err := r.db.QueryRow(`SELECT game_id, teams, winner, submitted_by, submitted_at FROM game_results
+		WHERE game_id = ?`, gameID).Scan(&result.GameID, &teams, &result.Winner, &result.SubmittedBy, &result.SubmittedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.GameResult{}, ErrNotFound
+	}
+	if err != nil {
+		return models.GameResult{}, err
+	}
+	if err := json.Unmarshal([]byte(teams), &result.Teams); err != nil {
+		return models.GameResult{}, fmt.Errorf("decode teams of game %s: %w", gameID, err)
+	}
+	return result, nil
+}
+
+func (r *sqliteRatingRepository) ListByGame(gameID string) ([]models.RatingChange, error) {
+	return r.queryChanges(`SELECT `+ratingChangeColumns+` FROM rating_changes
+		WHERE game_id = ? ORDER BY id`, gameID)
+}
+
+// queryChanges runs a rating change select and scans every row
+func (r *sqliteRatingRepository) queryChanges(query string, args ...any) ([]models.RatingChange, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.RatingChange
+	for rows.Next() {
+		var change models.RatingChange
+		if err := rows.Scan(&change.UserID, &change.GameID, &change.Score, &change.RatingBefore, &change.RatingAfter,
+			&change.DeviationBefore, &change.DeviationAfter, &change.VolatilityAfter, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *sqliteRatingRepository) RecordResult(result models.GameResult,
+	rate func(current map[string]models.Rating) []models.RatingChange) ([]models.RatingChange, error) {
+	teams, err := json.Marshal(result.Teams)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The result is inserted first so a second submission fails before any
+	// rating is read
+	_, err = tx.Exec(`INSERT INTO game_results (game_id, teams, winner, submitted_by, submitted_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		result.GameID, string(teams), result.Winner, result.SubmittedBy, result.SubmittedAt.UTC())
+	if isConstraintViolation(err) {
+		return nil, ErrAlreadyExists
+	}
+	if isForeignKeyViolation(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var players []string
+	for _, team := range result.Teams {
+		players = append(players, team...)
+	}
+	current, err := listRatings(tx, players)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := rate(current)
+	for _, change := range changes {
+		if _, err := tx.Exec(`INSERT INTO ratings (`+ratingColumns+`) VALUES (?, ?, ?, ?, 1, ?)
+			ON CONFLICT (user_id) DO UPDATE SET rating = excluded.rating, deviation = excluded.deviation,
+				volatility = excluded.volatility, games_rated = ratings.games_rated + 1, updated_at = excluded.updated_at`,
+			change.UserID, change.RatingAfter, change.DeviationAfter, change.VolatilityAfter, change.ChangedAt.UTC()); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`INSERT INTO rating_changes (`+ratingChangeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			change.UserID, change.GameID, change.Score, change.RatingBefore, change.RatingAfter,
+			change.DeviationBefore, change.DeviationAfter, change.VolatilityAfter, change.ChangedAt.UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, tx.Commit()
+}
+
+// sqliteFollowRepository is a FollowRepository backed by the follows table
+type sqliteFollowRepository struct {
+	db *sql.DB
+}
+
+func (r *sqliteFollowRepository) Follow(follow models.Follow) error {
+	_, err := r.db.Exec(`INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)`,
+		follow.FollowerID, follow.FolloweeID, follow.CreatedAt.UTC())
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	if isForeignKeyViolation(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *sqliteFollowRepository) Unfollow(followerID, followeeID string) error {
+	result, err := r.db.Exec(`DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteFollowRepository) ListFollowers(userID string) ([]models.Follow, error) {
+	return r.query(`SELECT follower_id, followee_id, created_at FROM follows
+		WHERE followee_id = ? ORDER BY created_at DESC, follower_id`, userID)
+}
+
+func (r *sqliteFollowRepository) ListFollowing(userID string) ([]models.Follow, error) {
+	return r.query(`SELECT follower_id, followee_id, created_at FROM follows
+		WHERE follower_id = ? ORDER BY created_at DESC, followee_id`, userID)
+}
+
+// query runs a follow select and scans every row
+func (r *sqliteFollowRepository) query(query string, args ...any) ([]models.Follow, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var follows []models.Follow
+	for rows.Next() {
+		var follow models.Follow
+		if err := rows.Scan(&follow.FollowerID, &follow.FolloweeID, &follow.CreatedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, follow)
+	}
+	return follows, rows.Err()
+}
+
+func (r *sqliteFollowRepository) DeleteByUser(userID string) error {
+	_, err := r.db.Exec(`DELETE FROM follows WHERE follower_id = ? OR followee_id = ?`, userID, userID)
+	return err
+}
+
+// sqliteClubRepository is a ClubRepository backed by the clubs, club_members
+// and club_join_requests tables
+type sqliteClubRepository struct {
+	db *sql.DB
+}
+
+const clubMemberColumns = `club_id, user_id, role, joined_at`
+
+const joinRequestColumns = `club_id, user_id, message, status, created_at, decided_by, decided_at`
+
+func scanJoinRequest(row scanner) (models.ClubJoinRequest, error) {
+	var request models.ClubJoinRequest
+	err := row.Scan(&request.ClubID, &request.UserID, &request.Message, &request.Status, &request.CreatedAt,
+		&request.DecidedBy, &request.DecidedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ClubJoinRequest{}, ErrNotFound
+	}
+	return request, err
+}
+
+func (r *sqliteClubRepository) Create(club models.Club, owner models.ClubMember) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO clubs (id, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		club.ID, club.Name, club.Description, club.CreatedAt.UTC(), club.UpdatedAt.UTC())
+	if isConstraintViolation(err) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO club_members (`+clubMemberColumns+`) VALUES (?, ?, ?, ?)`,
+		owner.ClubID, owner.UserID, owner.Role, owner.JoinedAt.UTC())
+	if isForeignKeyViolation(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteClubRepository) Get(id string) (models.Club, error) {
+	var club models.Club
+	err := r.db.QueryRow(`SELECT id, name, description, created_at, updated_at FROM clubs WHERE id = ?`, id).
+		Scan(&club.ID, &club.Name, &club.Description, &club.CreatedAt, &club.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Club{}, ErrNotFound
+	}
+	return club, err
+}
+
+func (r *sqliteClubRepository) Update(club models.Club) error {
+	result, err := r.db.Exec(`UPDATE clubs SET name = ?, description = ?, updated_at = ? WHERE id = ?`,
+		club.Name, club.Description, club.UpdatedAt.UTC(), club.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (r *sqliteClubRepository) GetMember(clubID, userID string) (models.ClubMember, error) {
+	var member models.ClubMember
+	err := r.db.QueryRow(`SELECT `+clubMemberColumns+` FROM club_members WHERE club_id = ? AND user_id = ?`, clubID, userID).
+		Scan(&member.ClubID, &member.UserID, &member.Role, &member.JoinedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ClubMember{}, ErrNotFound
+	}
+	return member, err
+}
+
+func (r *sqliteClubRepository) ListMembers(clubID string) ([]models.ClubMember, error) {
+	return r.queryMembers(`SELECT `+clubMemberColumns+` FROM club_members
+		WHERE club_id = ? ORDER BY joined_at, user_id`, clubID)
+}
+
+func (r *sqliteClubRepository) ListByUser(userID string) ([]models.ClubMember, error) {
+	return r.queryMembers(`SELECT `+clubMemberColumns+` FROM club_members
+		WHERE user_id = ? ORDER BY joined_at, club_id`, userID)
+}
+
+// queryMembers runs a club member select and scans every row
+func (r *sqliteClubRepository) queryMembers(query string, args ...any) ([]models.ClubMember, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.ClubMember
+	for rows.Next() {
+		var member models.ClubMember
+		if err := rows.Scan(&member.ClubID, &member.UserID, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (r *sqliteClubRepository) UpdateMembers(members []models.ClubMember) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, member := range members {
+		result, err := tx.Exec(`UPDATE club_members SET role = ? WHERE club_id = ? AND user_id = ?`,
+			member.Role, member.ClubID, member.UserID)
+		if err != nil {
+			return err
+		}
+		if err := checkAffected(result); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteClubRepository) RemoveMember(clubID, userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM club_members WHERE club_id = ? AND user_id = ?`, clubID, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+	if err := deleteEmptyClubs(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteClubRepository) RequestToJoin(request models.ClubJoinRequest) error {
+	result, err := r.db.Exec(`INSERT INTO club_join_requests (`+joinRequestColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (club_id, user_id) DO UPDATE SET message = excluded.message, status = excluded.status,
+			created_at = excluded.created_at, decided_by = excluded.decided_by, decided_at = excluded.decided_at
+		WHERE club_join_requests.status != 'pending'`,
+		request.ClubID, request.UserID, request.Message, request.Status, request.CreatedAt.UTC(),
+		request.DecidedBy, request.DecidedAt.UTC())
+	if isForeignKeyViolation(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// The upsert skips the row, changing nothing, when a request is pending
+	if checkAffected(result) != nil {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+func (r *sqliteClubRepository) GetJoinRequest(clubID, userID string) (models.ClubJoinRequest, error) {
+	return scanJoinRequest(r.db.QueryRow(`SELECT `+joinRequestColumns+` FROM club_join_requests
+		WHERE club_id = ? AND user_id = ?`, clubID, userID))
+}
+
+func (r *sqliteClubRepository) ListJoinRequests(clubID, status string) ([]models.ClubJoinRequest, error) {
+	return r.queryJoinRequests(`SELECT `+joinRequestColumns+` FROM club_join_requests
+		WHERE club_id = ? AND status = ? ORDER BY created_at, user_id`, clubID, status)
+}
+
+func (r *sqliteClubRepository) ListJoinRequestsByUser(userID string) ([]models.ClubJoinRequest, error) {
+	return r.queryJoinRequests(`SELECT `+joinRequestColumns+` FROM club_join_requests
+		WHERE user_id = ? ORDER BY created_at, club_id`, userID)
+}
+
+// queryJoinRequests runs a join request select and scans every row
+func (r *sqliteClubRepository) queryJoinRequests(query string, args ...any) ([]models.ClubJoinRequest, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.ClubJoinRequest
+	for rows.Next() {
+		request, err := scanJoinRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+func (r *sqliteClubRepository) DecideJoinRequest(request models.ClubJoinRequest) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE club_join_requests SET status = ?, decided_by = ?, decided_at = ?
+		WHERE club_id = ? AND user_id = ? AND status = 'pending'`,
+		request.Status, request.DecidedBy, request.DecidedAt.UTC(), request.ClubID, request.UserID)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+	if request.Status == models.JoinRequestApproved {
+		if _, err := tx.Exec(`INSERT INTO club_members (`+clubMemberColumns+`) VALUES (?, ?, ?, ?)`,
+			request.ClubID, request.UserID, models.ClubRoleMember, request.DecidedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteClubRepository) DeleteByUser(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteClubMemberships(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteClubMemberships removes a user's memberships and join requests and
+// deletes the clubs left without members
+func deleteClubMemberships(tx *sql.Tx, userID string) error {
+	if _, err := tx.Exec(`DELETE FROM club_members WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM club_join_requests WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return deleteEmptyClubs(tx)
+}
+
+// deleteEmptyClubs deletes the clubs without members together with their join
+// requests. Games the clubs owned keep their club ID.
+func deleteEmptyClubs(tx *sql.Tx) error {
+	const empty = `SELECT id FROM clubs WHERE NOT EXISTS (SELECT 1 FROM club_members m WHERE m.club_id = clubs.id)`
+	if _, err := tx.Exec(`DELETE FROM club_join_requests WHERE club_id IN (` + empty + `)`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM clubs WHERE id IN (` + empty + `)`)
+	return err
+}
+
+// sqliteStatsRepository is a StatsRepository that aggregates the other tables
+type sqliteStatsRepository struct {
+	db *sql.DB
+}
+
+func (r *sqliteStatsRepository) Get(now time.Time) (models.SystemStats, error) {
+	stats := models.SystemStats{
+		UsersByRole:   make(map[string]int),
+		GamesByStatus: make(map[string]int),
+		GeneratedAt:   now,
+	}
+
+	rows, err := r.db.Query(`SELECT role, COUNT(*), SUM(suspended_at != ?) FROM users GROUP BY role`, time.Time{})
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var role string
+		var count, suspended int
+		if err := rows.Scan(&role, &count, &suspended); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.UsersByRole[role] = count
+		stats.Users += count
+		stats.SuspendedUsers += suspended
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	rows, err = r.db.Query(`SELECT status, COUNT(*) FROM games GROUP BY status`)
+	if err != nil {
+		return stats, err
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.GamesByStatus[status] = count
+		stats.Games += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	err = r.db.QueryRow(`SELECT COUNT(DISTINCT g.id), COUNT(p.user_id) FROM games g
+		LEFT JOIN game_participants p ON p.game_id = g.id AND p.status = 'joined'
+		WHERE g.status IN ('scheduled', 'confirmed') AND g.start_time > ?`, now.UTC()).
+		Scan(&stats.UpcomingGames, &stats.Participants)
+	if err != nil {
+		return stats, err
+	}
+
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE revoked_at = ? AND expires_at > ?`,
+		time.Time{}, now.UTC()).Scan(&stats.ActiveSessions)
+	return stats, err
+}
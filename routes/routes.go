@@ -5,10 +5,15 @@ import (
 	
 	"rondo/handlers"
 	"rondo/middleware"
+	"rondo/models"
+	"rondo/utils"
 )
 
 // SetupRoutes configures all the routes for the application
 func SetupRoutes(r *gin.Engine) {
+	// Public keys for verifying access tokens
+	r.GET("/.well-known/jwks.json", handlers.JWKS)
+	
 	// Auth routes
 	auth := r.Group("/auth")
 	{
@@ -19,20 +24,59 @@ func SetupRoutes(r *gin.Engine) {
 			otp.POST("/verify", handlers.VerifyOTP)
 		}
 		
+		// Public email login endpoints, for accounts with a verified email
+		email := auth.Group("/email")
+		{
+			email.POST("/request", handlers.RequestEmailLogin)
+			email.POST("/verify", handlers.VerifyEmailLogin)
+		}
+		
+		// Public token refresh - authenticated by the refresh token itself
+		auth.POST("/refresh", handlers.RefreshToken)
+		
+		// User registration - requires the registration token from OTP verification
+		auth.POST("/register", middleware.AuthMiddleware(utils.ScopeRegister), handlers.RegisterUser)
+		
 		// Protected routes - require JWT authentication
 		protected := auth.Group("/")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(utils.ScopeUser))
 		{
-			// User registration - requires JWT from OTP verification
-			protected.POST("/register", handlers.RegisterUser)
+			// Session management
+			protected.POST("/logout", handlers.Logout)
+			protected.GET("/sessions", handlers.ListSessions)
+			protected.DELETE("/sessions/:id", handlers.RevokeSession)
 		}
 	}
 	
 	// User routes - protected by JWT authentication
 	users := r.Group("/users")
-	users.Use(middleware.AuthMiddleware()) // Apply JWT middleware to all user routes
+	users.Use(middleware.AuthMiddleware(utils.ScopeUser)) // Apply JWT middleware to all user routes
 	{
-		users.GET("/:phone", handlers.GetUserProfile)
+		// Own profile, and other users' public profiles by user ID
+		users.GET("/me", handlers.GetMyProfile)
+		users.PATCH("/me", handlers.UpdateMyProfile)
+		users.GET("/:id", handlers.GetPublicProfile)
+		users.GET("/:id/ratings", handlers.GetRatingHistory)
+		
+		// Follows; users who follow each other are friends
+		users.POST("/:id/follow", handlers.FollowUser)
+		users.DELETE("/:id/follow", handlers.UnfollowUser)
+		users.GET("/:id/followers", handlers.ListFollowers)
+		users.GET("/:id/following", handlers.ListFollowing)
+		users.GET("/me/friends", handlers.ListFriends)
+		users.GET("/me/clubs", handlers.ListMyClubs)
+		
+		// Phone number change, verified by a code sent to the new number
+		users.POST("/me/phone", handlers.RequestPhoneChange)
+		users.POST("/me/phone/verify", handlers.VerifyPhoneChange)
+		
+		// Email address to sign in with, verified by a code sent to it
+		users.POST("/me/email", handlers.RequestEmail)
+		users.POST("/me/email/verify", handlers.VerifyEmail)
+		
+		// Personal data export and account deletion
+		users.GET("/me/export", handlers.ExportAccount)
+		users.DELETE("/me", handlers.DeleteAccount)
 	}
 	
 	// Public game routes - no authentication required
@@ -40,11 +84,84 @@ func SetupRoutes(r *gin.Engine) {
 	
 	// Game routes - protected by JWT authentication
 	games := r.Group("/games")
-	games.Use(middleware.AuthMiddleware()) // Apply JWT middleware to all game routes
+	games.Use(middleware.AuthMiddleware(utils.ScopeUser)) // Apply JWT middleware to all game routes
 	{
 		games.POST("/create", handlers.CreateGame)
 		games.GET("/list", handlers.ListGames)
+		games.GET("/nearby", handlers.NearbyGames)
+		games.GET("/following", handlers.FollowingGames)
 		games.GET("/:id", handlers.GetGame)
+		games.PATCH("/:id", handlers.UpdateGame)
+		games.GET("/:id/history", handlers.GetGameHistory)
+		games.GET("/:id/ics", handlers.ExportGameCalendar)
 		games.POST("/join", handlers.JoinGame)
+		games.GET("/:id/participants", handlers.ListParticipants)
+		games.DELETE("/:id/participants/:user_id", handlers.RemoveParticipant)
+		games.POST("/:id/cancel", handlers.CancelGame)
+		games.POST("/:id/confirm", handlers.ConfirmGame)
+		games.POST("/:id/leave", handlers.LeaveGame)
+		games.POST("/:id/invite", handlers.InviteFriends)
+		games.POST("/:id/teams", handlers.SplitTeams)
+		games.GET("/:id/teams", handlers.GetTeams)
+		games.POST("/:id/result", handlers.SubmitGameResult)
+		games.GET("/:id/result", handlers.GetGameResult)
+		games.GET("/:id/waitlist/me", handlers.GetWaitlistPosition)
+		games.DELETE("/:id/waitlist/me", handlers.LeaveWaitlist)
+	}
+	
+	// Club routes - protected by JWT authentication. Only organizers start
+	// clubs; anyone can ask to join one.
+	clubs := r.Group("/clubs")
+	clubs.Use(middleware.AuthMiddleware(utils.ScopeUser))
+	{
+		clubs.POST("/create", middleware.RequireRole(models.RoleOrganizer), handlers.CreateClub)
+		clubs.GET("/:id", handlers.GetClub)
+		clubs.PATCH("/:id", handlers.UpdateClub)
+		clubs.GET("/:id/games", handlers.ClubGames)
+		
+		// Members and their roles; members leave by removing themselves
+		clubs.GET("/:id/members", handlers.ListClubMembers)
+		clubs.PUT("/:id/members/:user_id/role", handlers.ChangeClubRole)
+		clubs.DELETE("/:id/members/:user_id", handlers.RemoveClubMember)
+		
+		// Requests to join, decided by the club's admins
+		clubs.POST("/:id/join", handlers.RequestToJoinClub)
+		clubs.GET("/:id/join-requests", handlers.ListClubJoinRequests)
+		clubs.POST("/:id/join-requests/:user_id/approve", handlers.ApproveJoinRequest)
+		clubs.POST("/:id/join-requests/:user_id/reject", handlers.RejectJoinRequest)
+	}
+	
+	// Recurring series routes - protected by JWT authentication
+	series := r.Group("/series")
+	series.Use(middleware.AuthMiddleware(utils.ScopeUser))
+	{
+		series.POST("/create", handlers.CreateSeries)
+		series.GET("/:id", handlers.GetSeries)
+		series.PATCH("/:id", handlers.UpdateSeries)
+		series.POST("/:id/cancel", handlers.CancelSeries)
+	}
+	
+	// Calendar feed routes. The feed itself is authenticated by the secret
+	// token in its URL so calendar apps can subscribe to it.
+	r.GET("/calendar/feed/:token", handlers.CalendarFeed)
+	calendar := r.Group("/calendar")
+	calendar.Use(middleware.AuthMiddleware(utils.ScopeUser))
+	{
+		calendar.POST("/feed", handlers.CreateCalendarFeed)
+		calendar.DELETE("/feed", handlers.RevokeCalendarFeed)
+	}
+	
+	// Admin routes - require the admin role
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(utils.ScopeUser), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", handlers.AdminListUsers)
+		admin.GET("/users/by-phone/:phone", handlers.AdminGetUserByPhone)
+		admin.GET("/users/:id", handlers.AdminGetUser)
+		admin.PUT("/users/:id/role", handlers.AdminUpdateRole)
+		admin.POST("/users/:id/suspend", handlers.AdminSuspendUser)
+		admin.DELETE("/users/:id/suspend", handlers.AdminUnsuspendUser)
+		admin.POST("/games/:id/cancel", handlers.AdminCancelGame)
+		admin.GET("/stats", handlers.AdminStats)
 	}
 }
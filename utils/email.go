@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rondo/config"
+)
+
+// Email providers selectable with EMAIL_PROVIDER
+const (
+	EmailProviderSMTP   = "smtp"
+	EmailProviderLog    = "log"
+	EmailProviderMemory = "memory"
+)
+
+// ErrInvalidEmail is returned for input that is not a valid email address
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// EmailSender delivers plain text emails
+type EmailSender interface {
+	SendEmail(to, subject, body string) error
+}
+
+// EmailMessage is an email recorded by the memory sender
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+	SentAt  time.Time
+}
+
+// NewEmailSender returns the sender selected by the configuration
+func NewEmailSender(cfg config.Config) (EmailSender, error) {
+	switch cfg.EmailProvider {
+	case EmailProviderSMTP:
+		return NewSMTPEmail(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.EmailFrom)
+	case EmailProviderLog:
+		return LogEmail{}, nil
+	case EmailProviderMemory:
+		return NewMemoryEmail(), nil
+	default:
+		return nil, fmt.Errorf("unknown email provider %q", cfg.EmailProvider)
+	}
+}
+
+// NormalizeEmail validates a bare email address such as "Ana@Example.com"
+// and returns it in lower case
+func NormalizeEmail(input string) (string, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(input))
+	if err != nil || address.Name != "" || address.Address != strings.TrimSpace(input) {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+// LogEmail writes emails to the server log instead of sending them. It is
+// meant for local development.
+type LogEmail struct{}
+
+// SendEmail logs an email
+func (LogEmail) SendEmail(to, subject, body string) error {
+	log.Printf("Email to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// MemoryEmail keeps sent emails in memory for tests to inspect
+type MemoryEmail struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+// NewMemoryEmail returns an empty in-memory sender
+func NewMemoryEmail() *MemoryEmail {
+	return &MemoryEmail{}
+}
+
+// SendEmail records an email
+func (m *MemoryEmail) SendEmail(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, EmailMessage{To: to, Subject: subject, Body: body, SentAt: time.Now()})
+	return nil
+}
+
+// Messages returns the emails sent to an address, oldest first
+func (m *MemoryEmail) Messages(to string) []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []EmailMessage
+	for _, message := range m.messages {
+		if message.To == to {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// SMTPEmail sends emails through an SMTP server. The connection is upgraded
+// with STARTTLS whenever the server offers it, and credentials are only sent
+// over TLS or to a server on the local machine.
+type SMTPEmail struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPEmail returns a sender relaying through host:port as from. An empty
+// username disables authentication.
+func NewSMTPEmail(host string, port int, username, password, from string) (*SMTPEmail, error) {
+	if host == "" || from == "" {
+		return nil, fmt.Errorf("SMTP_HOST and EMAIL_FROM must be set for the smtp email provider")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_FROM %q: %w", from, err)
+	}
+	return &SMTPEmail{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}, nil
+}
+
+// SendEmail delivers an email over a new SMTP connection
+func (s *SMTPEmail) SendEmail(to, subject, body string) error {
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", s.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if _, err := writer.Write(formatEmail(s.from, to, subject, body)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return client.Quit()
+}
+
+// formatEmail builds a plain text message with CRLF line endings
+func formatEmail(from, to, subject, body string) []byte {
+	var message strings.Builder
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + to + "\r\n")
+	message.WriteString("Subject: " + mimeHeader(subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	message.WriteString("\r\n")
+	return []byte(message.String())
+}
+
+// mimeHeader encodes a header value so that line breaks cannot inject headers
+// and non-ASCII text survives transport
+func mimeHeader(value string) string {
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	return mime.QEncoding.Encode("utf-8", value)
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// smtpStandIn is a minimal SMTP server that accepts one message per
+// connection and records the envelope and data it received
+type smtpStandIn struct {
+	listener net.Listener
+	received chan smtpDelivery
+	// rejectRcpt makes the server refuse every recipient
+	rejectRcpt bool
+}
+
+type smtpDelivery struct {
+	from, to, data string
+}
+
+func newSMTPStandIn(t *testing.T, rejectRcpt bool) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &smtpStandIn{listener: listener, received: make(chan smtpDelivery, 1), rejectRcpt: rejectRcpt}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *smtpStandIn) hostPort(t *testing.T) (string, int) {
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split address: %v", err)
+	}
+	number, _ := strconv.Atoi(port)
+	return host, number
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var delivery smtpDelivery
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			delivery.from = strings.TrimPrefix(command, "MAIL FROM:")
+			reply("250 OK")
+		case "RCPT":
+			if s.rejectRcpt {
+				reply("550 No such user")
+				continue
+			}
+			delivery.to = strings.TrimPrefix(command, "RCPT TO:")
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			delivery.data = data.String()
+			s.received <- delivery
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPEmailSendsToStandIn(t *testing.T) {
+	server := newSMTPStandIn(t, false)
+	host, port := server.hostPort(t)
+
+	sender, err := NewSMTPEmail(host, port, "", "", "Rondo <no-reply@example.com>")
+	if err != nil {
+		t.Fatalf("new smtp sender: %v", err)
+	}
+	if err := sender.SendEmail("ana@example.com", "Your login code", "Your login code is: 123456"); err != nil {
+		t.Fatalf("send email: %v", err)
+	}
+
+	delivery := <-server.received
+	if delivery.from != "<no-reply@example.com>" || delivery.to != "<ana@example.com>" {
+		t.Errorf("envelope from %q to %q", delivery.from, delivery.to)
+	}
+	if !strings.Contains(delivery.data, "Subject: Your login code\r\n") {
+		t.Errorf("data %q has no subject", delivery.data)
+	}
+	if !strings.Contains(delivery.data, "\r\n\r\nYour login code is: 123456\r\n") {
+		t.Errorf("data %q has no body", delivery.data)
+	}
+}
+
+func TestSMTPEmailReportsRejectedRecipients(t *testing.T) {
+	server := newSMTPStandIn(t, true)
+	host, port := server.hostPort(t)
+
+	sender, err := NewSMTPEmail(host, port, "", "", "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("new smtp sender: %v", err)
+	}
+	if err := sender.SendEmail("nobody@example.com", "Hello", "Hello"); err == nil {
+		t.Error("send to rejected recipient: expected an error")
+	}
+}
+
+func TestFormatEmailKeepsHeadersOnOneLine(t *testing.T) {
+	message := string(formatEmail("no-reply@example.com", "ana@example.com", "Hi\r\nBcc: eve@example.com", "Line one\nLine two"))
+
+	if strings.Contains(message, "\r\nBcc:") {
+		t.Errorf("subject injected a header: %q", message)
+	}
+	if !strings.Contains(message, "Line one\r\nLine two\r\n") {
+		t.Errorf("body lines not CRLF terminated: %q", message)
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		input, want string
+		valid       bool
+	}{
+		{"Ana@Example.com", "ana@example.com", true},
+		{"  ana@example.com ", "ana@example.com", true},
+		{"Ana <ana@example.com>", "", false},
+		{"not an address", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, err := NormalizeEmail(test.input)
+		if (err == nil) != test.valid || got != test.want {
+			t.Errorf("NormalizeEmail(%q) = %q, %v", test.input, got, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// GeohashPrecision is the length of the geohashes stored for games. Cells at
+// this precision are roughly 1.2km by 0.6km.
+const GeohashPrecision = 6
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// kmPerDegree is the length of one degree of latitude
+const kmPerDegree = 111.32
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash encodes a coordinate as a geohash of the given length. Nearby
+// coordinates share a common prefix, so a prefix selects a rectangular cell.
+func Geohash(lat, lng float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+
+	var hash strings.Builder
+	bit, index, even := 0, 0, true
+	for hash.Len() < precision {
+		// Bits alternate between longitude and latitude, starting with longitude
+		if even {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				index = index<<1 | 1
+				minLng = mid
+			} else {
+				index <<= 1
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				index = index<<1 | 1
+				minLat = mid
+			} else {
+				index <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[index])
+			bit, index = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// geohashCellSize returns the height and width in degrees of a geohash cell
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	latBits := bits / 2
+	lngBits := bits - latBits
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lngBits))
+}
+
+// GeohashCover returns geohash prefixes whose cells together contain every
+// point within radiusKm of a coordinate. It picks the longest prefix whose
+// cells are at least radiusKm across and returns that cell and its neighbours.
+func GeohashCover(lat, lng, radiusKm float64) []string {
+	// Cells narrow towards the poles, so size them at the furthest latitude the radius reaches
+	furthestLat := math.Min(math.Abs(lat)+radiusKm/kmPerDegree, 90)
+
+	for precision := GeohashPrecision; precision >= 1; precision-- {
+		height, width := geohashCellSize(precision)
+		if height*kmPerDegree < radiusKm || width*kmPerDegree*math.Cos(furthestLat*math.Pi/180) < radiusKm {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var cover []string
+		for dLat := -1.0; dLat <= 1; dLat++ {
+			neighbourLat := lat + dLat*height
+			if neighbourLat < -90 || neighbourLat > 90 {
+				continue
+			}
+			for dLng := -1.0; dLng <= 1; dLng++ {
+				hash := Geohash(neighbourLat, wrapLongitude(lng+dLng*width), precision)
+				if !seen[hash] {
+					seen[hash] = true
+					cover = append(cover, hash)
+				}
+			}
+		}
+		return cover
+	}
+
+	// Even the largest cells are too narrow this close to a pole
+	return strings.Split(geohashAlphabet, "")
+}
+
+// wrapLongitude maps a longitude into [-180, 180)
+func wrapLongitude(lng float64) float64 {
+	return math.Mod(math.Mod(lng+180, 360)+360, 360) - 180
+}
+
+// DistanceKm returns the great-circle distance between two coordinates
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(math.Min(a, 1)))
+}
@@ -0,0 +1,146 @@
+package utils
+
+import "math"
+
+// Starting rating of players who have not been rated yet
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+)
+
+// Glicko-2 system constants. glickoTau limits how quickly volatility can
+// change; Glickman suggests values between 0.3 and 1.2.
+const (
+	glickoTau     = 0.5
+	glickoScale   = 173.7178 // Converts between the Glicko and Glicko-2 scales
+	glickoEpsilon = 0.000001 // Convergence tolerance of the volatility iteration
+)
+
+// GlickoRating is a player's strength in the Glicko-2 system, expressed on
+// the familiar Glicko scale: a rating around 1500 and a rating deviation
+// that shrinks as the rating becomes more certain
+type GlickoRating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+// NewGlickoRating returns the rating of an unrated player
+func NewGlickoRating() GlickoRating {
+	return GlickoRating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// GlickoResult is the outcome of one game against an opponent. Score is 1
+// for a win, 0.5 for a draw and 0 for a loss.
+type GlickoResult struct {
+	Opponent GlickoRating
+	Score    float64
+}
+
+// UpdateGlicko returns a player's rating after a rating period with the given
+// results, following Glickman's "Example of the Glicko-2 system". A period
+// without results only widens the deviation.
+func UpdateGlicko(player GlickoRating, results []GlickoResult) GlickoRating {
+	mu := (player.Rating - DefaultRating) / glickoScale
+	phi := player.Deviation / glickoScale
+	sigma := player.Volatility
+
+	if len(results) == 0 {
+		player.Deviation = math.Sqrt(phi*phi+sigma*sigma) * glickoScale
+		return player
+	}
+
+	// Estimated variance of the rating from the results alone, and the
+	// estimated improvement over the pre-period rating
+	var variance, improvement float64
+	for _, result := range results {
+		muJ := (result.Opponent.Rating - DefaultRating) / glickoScale
+		phiJ := result.Opponent.Deviation / glickoScale
+		g := glickoG(phiJ)
+		e := glickoE(mu, muJ, g)
+		variance += g * g * e * (1 - e)
+		improvement += g * (result.Score - e)
+	}
+	v := 1 / variance
+	delta := v * improvement
+
+	sigma = glickoVolatility(phi, sigma, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*improvement
+
+	return GlickoRating{
+		Rating:     newMu*glickoScale + DefaultRating,
+		Deviation:  newPhi * glickoScale,
+		Volatility: sigma,
+	}
+}
+
+// TeamGlickoRating combines the ratings of a team into a single opponent: the
+// mean rating, with the deviation of the mean squared deviation
+func TeamGlickoRating(team []GlickoRating) GlickoRating {
+	if len(team) == 0 {
+		return NewGlickoRating()
+	}
+
+	var combined GlickoRating
+	for _, player := range team {
+		combined.Rating += player.Rating
+		combined.Deviation += player.Deviation * player.Deviation
+		combined.Volatility += player.Volatility
+	}
+	n := float64(len(team))
+	combined.Rating /= n
+	combined.Deviation = math.Sqrt(combined.Deviation / n)
+	combined.Volatility /= n
+	return combined
+}
+
+// glickoG weighs an opponent's result by how certain their rating is
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// glickoE is the expected score against an opponent
+func glickoE(mu, muJ, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-muJ)))
+}
+
+// glickoVolatility finds the new volatility with the Illinois algorithm
+// (step 5 of Glickman's example)
+func glickoVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	// A and B bracket the solution, named as in the paper
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
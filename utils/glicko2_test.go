@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// TestUpdateGlickoMatchesReferenceExample checks the worked example from
+// Glickman's "Example of the Glicko-2 system"
+func TestUpdateGlickoMatchesReferenceExample(t *testing.T) {
+	player := GlickoRating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	results := []GlickoResult{
+		{Opponent: GlickoRating{Rating: 1400, Deviation: 30, Volatility: 0.06}, Score: 1},
+		{Opponent: GlickoRating{Rating: 1550, Deviation: 100, Volatility: 0.06}, Score: 0},
+		{Opponent: GlickoRating{Rating: 1700, Deviation: 300, Volatility: 0.06}, Score: 0},
+	}
+
+	got := UpdateGlicko(player, results)
+
+	if math.Abs(got.Rating-1464.06) > 0.01 {
+		t.Errorf("rating = %.4f, want 1464.06", got.Rating)
+	}
+	if math.Abs(got.Deviation-151.52) > 0.01 {
+		t.Errorf("deviation = %.4f, want 151.52", got.Deviation)
+	}
+	if math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Errorf("volatility = %.6f, want 0.05999", got.Volatility)
+	}
+}
+
+func TestUpdateGlickoWithoutResultsWidensDeviation(t *testing.T) {
+	player := GlickoRating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+
+	got := UpdateGlicko(player, nil)
+
+	// sqrt(200² + (0.06 × 173.7178)²)
+	if got.Rating != 1500 || math.Abs(got.Deviation-200.2714) > 0.0001 || got.Volatility != 0.06 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestUpdateGlickoNewPlayers(t *testing.T) {
+	winner := UpdateGlicko(NewGlickoRating(), []GlickoResult{{Opponent: NewGlickoRating(), Score: 1}})
+	loser := UpdateGlicko(NewGlickoRating(), []GlickoResult{{Opponent: NewGlickoRating(), Score: 0}})
+	drawn := UpdateGlicko(NewGlickoRating(), []GlickoResult{{Opponent: NewGlickoRating(), Score: 0.5}})
+
+	// Reference values for two unrated players, computed independently from the
+	// formulas in Glickman's example
+	if math.Abs(winner.Rating-1662.31) > 0.01 || math.Abs(winner.Deviation-290.32) > 0.01 {
+		t.Errorf("winner = %+v, want 1662.31 ± 290.32", winner)
+	}
+	if math.Abs(winner.Rating-DefaultRating-(DefaultRating-loser.Rating)) > 1e-9 {
+		t.Errorf("winner gained %.4f but loser lost %.4f", winner.Rating-DefaultRating, DefaultRating-loser.Rating)
+	}
+	if drawn.Rating != DefaultRating || drawn.Deviation >= DefaultDeviation {
+		t.Errorf("draw = %+v, want an unchanged rating with a smaller deviation", drawn)
+	}
+}
+
+func TestUpdateGlickoIsDeterministic(t *testing.T) {
+	player := GlickoRating{Rating: 1620, Deviation: 80, Volatility: 0.059}
+	results := []GlickoResult{
+		{Opponent: GlickoRating{Rating: 1580, Deviation: 120, Volatility: 0.06}, Score: 1},
+		{Opponent: GlickoRating{Rating: 1710, Deviation: 60, Volatility: 0.06}, Score: 0.5},
+	}
+
+	first := UpdateGlicko(player, results)
+	for i := 0; i < 10; i++ {
+		if got := UpdateGlicko(player, results); got != first {
+			t.Fatalf("run %d = %+v, first run = %+v", i, got, first)
+		}
+	}
+}
+
+func TestTeamGlickoRating(t *testing.T) {
+	team := []GlickoRating{
+		{Rating: 1400, Deviation: 30, Volatility: 0.06},
+		{Rating: 1600, Deviation: 40, Volatility: 0.06},
+	}
+
+	got := TeamGlickoRating(team)
+
+	// The mean rating, and the root mean square of the deviations
+	if got.Rating != 1500 || math.Abs(got.Deviation-math.Sqrt(1250)) > 1e-9 {
+		t.Errorf("got %+v", got)
+	}
+	if empty := TeamGlickoRating(nil); empty != NewGlickoRating() {
+		t.Errorf("empty team = %+v", empty)
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"rondo/models"
+)
+
+// icalTimeFormat is the RFC 5545 UTC date-time format
+const icalTimeFormat = "20060102T150405Z"
+
+// icalStatuses maps game statuses to VEVENT statuses
+var icalStatuses = map[string]string{
+	models.GameScheduled: "TENTATIVE",
+	models.GameConfirmed: "CONFIRMED",
+	models.GameCancelled: "CANCELLED",
+	models.GameCompleted: "CONFIRMED",
+}
+
+// GameCalendar renders games as an RFC 5545 calendar with one VEVENT per game.
+// Each event's UID is derived from the game ID and its SEQUENCE from the
+// game's revision, so calendar clients replace earlier copies of an event
+// when the game is edited or cancelled.
+func GameCalendar(name string, games []models.Game, now time.Time) string {
+	var cal icalWriter
+	cal.line("BEGIN", "VCALENDAR")
+	cal.line("VERSION", "2.0")
+	cal.line("PRODID", "-//Rondo//Games//EN")
+	cal.line("CALSCALE", "GREGORIAN")
+	cal.line("METHOD", "PUBLISH")
+	cal.line("X-WR-CALNAME", icalText(name))
+
+	for _, game := range games {
+		cal.line("BEGIN", "VEVENT")
+		cal.line("UID", game.ID+"@rondo")
+		cal.line("DTSTAMP", now.UTC().Format(icalTimeFormat))
+		cal.line("DTSTART", game.StartTime.UTC().Format(icalTimeFormat))
+		cal.line("DTEND", game.EndTime.UTC().Format(icalTimeFormat))
+		cal.line("SEQUENCE", fmt.Sprint(game.Sequence))
+		cal.line("LAST-MODIFIED", game.UpdatedAt.UTC().Format(icalTimeFormat))
+		cal.line("SUMMARY", icalText(game.EventName))
+		cal.line("LOCATION", icalText(game.Location))
+		if game.Coordinates != nil {
+			cal.line("GEO", fmt.Sprintf("%f;%f", game.Coordinates.Latitude, game.Coordinates.Longitude))
+		}
+		if game.Status == models.GameCancelled && game.CancelReason != "" {
+			cal.line("DESCRIPTION", icalText("Cancelled: "+game.CancelReason))
+		}
+		cal.line("STATUS", icalStatuses[game.Status])
+		cal.line("END", "VEVENT")
+	}
+
+	cal.line("END", "VCALENDAR")
+	return cal.String()
+}
+
+// icalText escapes a TEXT property value
+func icalText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// icalWriter builds content lines, folding them at 75 octets as RFC 5545 requires
+type icalWriter struct {
+	strings.Builder
+}
+
+func (w *icalWriter) line(name, value string) {
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		// Fold before a UTF-8 continuation byte would split a character
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = 74
+	}
+	w.WriteString(line + "\r\n")
+}
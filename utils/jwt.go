@@ -2,10 +2,10 @@ package utils
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	
 	"rondo/models"
 )
@@ -16,19 +16,31 @@ type JWTClaims struct {
 	Phone     string `json:"phone"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a JWT token for the user
-func GenerateJWT(user models.User) (string, error) {
-	// Get JWT secret from environment variable
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "default_jwt_secret_please_change_in_production" // Default for development
-	}
-	
-	// Set expiration time (24 hours)
-	expirationTime := time.Now().Add(24 * time.Hour)
+// Token scopes. User tokens belong to a session and can use the API.
+// Registration tokens only let a verified phone number register, once.
+const (
+	ScopeUser     = "user"
+	ScopeRegister = "register"
+)
+
+// AccessTokenTTL is how long an access token is valid. Clients use their
+// refresh token to get a new one.
+const AccessTokenTTL = 15 * time.Minute
+
+// RegistrationTokenTTL is how long a phone number has to register after
+// verifying it
+const RegistrationTokenTTL = 10 * time.Minute
+
+// GenerateJWT generates a user access token for the session sessionID
+func GenerateJWT(user models.User, sessionID string) (string, error) {
+	// Set expiration time
+	expirationTime := time.Now().Add(AccessTokenTTL)
 	
 	// Create claims
 	claims := JWTClaims{
@@ -36,6 +48,9 @@ func GenerateJWT(user models.User) (string, error) {
 		Phone:     user.Phone,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
+		Role:      user.Role,
+		SessionID: sessionID,
+		Scope:     ScopeUser,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -45,11 +60,36 @@ func GenerateJWT(user models.User) (string, error) {
 		},
 	}
 	
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return signJWT(claims)
+}
+
+// GenerateRegistrationJWT generates a registration token for a verified phone
+// number. Its ID lets the registration mark it as used.
+func GenerateRegistrationJWT(phone string) (string, error) {
+	now := time.Now()
+	claims := JWTClaims{
+		Phone: phone,
+		Scope: ScopeRegister,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(RegistrationTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "rondo-api",
+		},
+	}
+	
+	return signJWT(claims)
+}
+
+// signJWT signs claims with the active key, naming it in the header
+func signJWT(claims JWTClaims) (string, error) {
+	kid, algorithm, key := Keys.SigningKey()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(algorithm), claims)
+	token.Header["kid"] = kid
 	
 	// Sign token
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", err
 	}
@@ -59,21 +99,20 @@ func GenerateJWT(user models.User) (string, error) {
 
 // ValidateJWT validates a JWT token and returns the claims
 func ValidateJWT(tokenString string) (*JWTClaims, error) {
-	// Get JWT secret from environment variable
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "default_jwt_secret_please_change_in_production" // Default for development
-	}
-	
 	// Parse token
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// Look up the key named in the header and check it signs with the token's algorithm
+		kid, _ := token.Header["kid"].(string)
+		algorithm, key, err := Keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		
-		return []byte(jwtSecret), nil
-	})
+		return key, nil
+	}, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}))
 	
 	if err != nil {
 		return nil, err
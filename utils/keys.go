@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported token signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// createdHeader is the PEM header recording when a key was generated
+const createdHeader = "Created"
+
+// kidTimeFormat is the creation time prefix of generated kids
+const kidTimeFormat = "20060102T150405Z"
+
+// Keys is the key manager used to sign and verify access tokens
+var Keys *KeyManager
+
+// KeyManager holds the keys that sign and verify access tokens. Keys are
+// PKCS#8 PEM files in a directory, named <kid>.pem, with their creation time
+// in a Created header so copying or touching a file does not change its age.
+// The newest key signs new tokens. When it is older than the rotation period
+// a new key is generated, and older keys keep verifying tokens for a grace
+// period after their successor was created before being deleted.
+type KeyManager struct {
+	mu        sync.RWMutex
+	dir       string
+	algorithm string
+	rotation  time.Duration
+	grace     time.Duration
+	keys      []signingKey // Oldest first; the last key is the active one
+}
+
+// signingKey is a private key identified by its kid
+type signingKey struct {
+	id        string
+	algorithm string
+	private   crypto.Signer
+	createdAt time.Time
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"` // OKP keys
+	X         string `json:"x,omitempty"`   // OKP keys
+	N         string `json:"n,omitempty"`   // RSA keys
+	E         string `json:"e,omitempty"`   // RSA keys
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// InitKeys loads the signing keys from dir, generating one with the given
+// algorithm if there are none or the newest is due for rotation, and makes
+// them the keys used for access tokens
+func InitKeys(dir, algorithm string, rotation, grace time.Duration) error {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return fmt.Errorf("unsupported signing algorithm %q, use %s or %s", algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+	if grace < AccessTokenTTL {
+		return fmt.Errorf("key grace period %s is shorter than the %s access token lifetime", grace, AccessTokenTTL)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	manager := &KeyManager{dir: dir, algorithm: algorithm, rotation: rotation, grace: grace}
+	if err := manager.Rotate(time.Now()); err != nil {
+		return err
+	}
+	Keys = manager
+	return nil
+}
+
+// StartRotation checks on an interval whether the signing key is due for
+// rotation. Rotation reloads the key directory first, so several servers
+// sharing it pick up each other's keys.
+func (m *KeyManager) StartRotation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := m.Rotate(now); err != nil {
+				log.Printf("Failed to rotate signing keys: %v", err)
+			}
+		}
+	}()
+}
+
+// Rotate reloads the keys, generates a new signing key if the active one is
+// older than the rotation period and deletes keys whose grace period is over
+func (m *KeyManager) Rotate(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 || now.Sub(keys[len(keys)-1].createdAt) >= m.rotation {
+		key, err := m.generate(now)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		log.Printf("Generated %s signing key %s", key.algorithm, key.id)
+	}
+
+	// A key stops verifying once its successor has been signing for the grace period
+	var active []signingKey
+	for i, key := range keys {
+		if i < len(keys)-1 && now.Sub(keys[i+1].createdAt) > m.grace {
+			if err := os.Remove(m.path(key.id)); err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to delete expired signing key %s: %v", key.id, err)
+			}
+			continue
+		}
+		active = append(active, key)
+	}
+
+	m.keys = active
+	return nil
+}
+
+// load reads every key in the key directory, oldest first
+func (m *KeyManager) load() ([]signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	// A stray file in the directory should not stop the server from signing
+	var keys []signingKey
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", path, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].createdAt.Equal(keys[j].createdAt) {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].createdAt.Before(keys[j].createdAt)
+	})
+	return keys, nil
+}
+
+// loadKey parses a PEM private key file. Its kid is the file name without
+// the extension. Its creation time is read from the Created header, or from
+// the kid of keys generated before the header was written; keys with neither
+// count as the oldest, so they are rotated out first.
+func loadKey(path string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, fmt.Errorf("no PEM data found")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	key := signingKey{id: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	if created, ok := block.Headers[createdHeader]; ok {
+		if key.createdAt, err = time.Parse(time.RFC3339Nano, created); err != nil {
+			return signingKey{}, fmt.Errorf("invalid %s header: %w", createdHeader, err)
+		}
+	} else if prefix, _, found := strings.Cut(key.id, "-"); found {
+		key.createdAt, _ = time.Parse(kidTimeFormat, prefix)
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.algorithm, key.private = AlgorithmRS256, private
+	case ed25519.PrivateKey:
+		key.algorithm, key.private = AlgorithmEdDSA, private
+	default:
+		return signingKey{}, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// generate creates a key with the manager's algorithm and saves it
+func (m *KeyManager) generate(now time.Time) (signingKey, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return signingKey{}, err
+	}
+	key := signingKey{
+		id:        now.UTC().Format(kidTimeFormat) + "-" + hex.EncodeToString(suffix),
+		algorithm: m.algorithm,
+		createdAt: now,
+	}
+
+	var err error
+	if m.algorithm == AlgorithmRS256 {
+		key.private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	} else {
+		_, key.private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return signingKey{}, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdHeader: now.UTC().Format(time.RFC3339Nano)},
+		Bytes:   der,
+	})
+	if err := os.WriteFile(m.path(key.id), data, 0o600); err != nil {
+		return signingKey{}, err
+	}
+	return key, nil
+}
+
+func (m *KeyManager) path(kid string) string {
+	return filepath.Join(m.dir, kid+".pem")
+}
+
+// SigningKey returns the active key's kid, algorithm and private key
+func (m *KeyManager) SigningKey() (string, string, crypto.Signer) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := m.keys[len(m.keys)-1]
+	return key.id, key.algorithm, key.private
+}
+
+// VerificationKey returns the algorithm and public key of a kid
+func (m *KeyManager) VerificationKey(kid string) (string, crypto.PublicKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.keys {
+		if key.id == kid {
+			return key.algorithm, key.private.Public(), nil
+		}
+	}
+	return "", nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWKS returns the public keys that currently verify tokens
+func (m *KeyManager) JWKS() JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range m.keys {
+		jwk := JWK{KeyID: key.id, Use: "sig", Algorithm: key.algorithm}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
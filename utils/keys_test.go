@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"rondo/models"
+)
+
+// initTestKeys makes a fresh key directory the one used for access tokens
+func initTestKeys(t *testing.T, algorithm string, rotation, grace time.Duration) string {
+	t.Helper()
+
+	previous := Keys
+	t.Cleanup(func() { Keys = previous })
+
+	dir := t.TempDir()
+	if err := InitKeys(dir, algorithm, rotation, grace); err != nil {
+		t.Fatalf("init keys: %v", err)
+	}
+	return dir
+}
+
+// testToken signs an access token with the active key
+func testToken(t *testing.T) string {
+	t.Helper()
+
+	token, err := GenerateJWT(models.User{ID: "user", Role: models.RolePlayer}, "session")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+func TestKeysRotateAfterRotationPeriod(t *testing.T) {
+	const rotation, grace = 24 * time.Hour, time.Hour
+	dir := initTestKeys(t, AlgorithmEdDSA, rotation, grace)
+	first, _, _ := Keys.SigningKey()
+
+	// The key's age comes from its contents, not the file's modification time
+	old := time.Now().Add(-365 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, first+".pem"), old, old); err != nil {
+		t.Fatalf("touch key: %v", err)
+	}
+	if err := Keys.Rotate(time.Now().Add(rotation / 2)); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if kid, _, _ := Keys.SigningKey(); kid != first {
+		t.Errorf("key rotated to %s before the rotation period", kid)
+	}
+
+	if err := Keys.Rotate(time.Now().Add(rotation)); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	second, _, _ := Keys.SigningKey()
+	if second == first {
+		t.Fatalf("key %s was not rotated after the rotation period", first)
+	}
+	if got := len(Keys.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS has %d keys during the grace period, want 2", got)
+	}
+}
+
+func TestPreviousKeyVerifiesDuringGracePeriod(t *testing.T) {
+	const rotation, grace = 24 * time.Hour, time.Hour
+	initTestKeys(t, AlgorithmEdDSA, rotation, grace)
+	token := testToken(t)
+
+	rotatedAt := time.Now().Add(rotation)
+	if err := Keys.Rotate(rotatedAt); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := Keys.Rotate(rotatedAt.Add(grace / 2)); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := ValidateJWT(token); err != nil {
+		t.Errorf("token signed by the previous key rejected during the grace period: %v", err)
+	}
+
+	if err := Keys.Rotate(rotatedAt.Add(grace + time.Second)); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := ValidateJWT(token); err == nil {
+		t.Error("token signed by the previous key accepted after the grace period")
+	}
+	if got := len(Keys.JWKS().Keys); got != 1 {
+		t.Errorf("JWKS has %d keys after the grace period, want 1", got)
+	}
+}
+
+func TestValidateRejectsUnknownKeysAndAlgorithms(t *testing.T) {
+	initTestKeys(t, AlgorithmEdDSA, 24*time.Hour, time.Hour)
+	foreign := testToken(t)
+
+	// Tokens from another key directory name a kid this one does not have
+	initTestKeys(t, AlgorithmEdDSA, 24*time.Hour, time.Hour)
+	if _, err := ValidateJWT(foreign); err == nil {
+		t.Error("token with an unknown kid accepted")
+	}
+
+	// A token naming the active kid but signed with another algorithm
+	kid, _, _ := Keys.SigningKey()
+	claims := JWTClaims{
+		UserID: "user",
+		Scope:  ScopeUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	for method, key := range map[jwt.SigningMethod]any{
+		jwt.SigningMethodRS256: rsaKey,
+		jwt.SigningMethodHS256: []byte("secret"),
+	} {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign %s token: %v", method.Alg(), err)
+		}
+		if _, err := ValidateJWT(signed); err == nil {
+			t.Errorf("%s token for an EdDSA key accepted", method.Alg())
+		}
+	}
+}
+
+func TestUnparseableKeyFilesAreSkipped(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	previous := Keys
+	t.Cleanup(func() { Keys = previous })
+	if err := InitKeys(dir, AlgorithmEdDSA, 24*time.Hour, time.Hour); err != nil {
+		t.Fatalf("init keys with a broken file: %v", err)
+	}
+	if _, err := ValidateJWT(testToken(t)); err != nil {
+		t.Errorf("validate: %v", err)
+	}
+}
+
+func TestJWKSPublishesPublicKeys(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			initTestKeys(t, algorithm, 24*time.Hour, time.Hour)
+			kid, _, private := Keys.SigningKey()
+
+			set := Keys.JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("JWKS has %d keys, want 1", len(set.Keys))
+			}
+			jwk := set.Keys[0]
+			if jwk.KeyID != kid || jwk.Algorithm != algorithm || jwk.Use != "sig" {
+				t.Errorf("JWK %+v, want kid %s, alg %s and use sig", jwk, kid, algorithm)
+			}
+
+			switch public := private.Public().(type) {
+			case *rsa.PublicKey:
+				n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+				e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+				if jwk.KeyType != "RSA" || new(big.Int).SetBytes(n).Cmp(public.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(public.E) {
+					t.Errorf("RSA JWK %+v does not match the public key", jwk)
+				}
+			case ed25519.PublicKey:
+				x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+				if jwk.KeyType != "OKP" || jwk.Curve != "Ed25519" || !public.Equal(ed25519.PublicKey(x)) {
+					t.Errorf("OKP JWK %+v does not match the public key", jwk)
+				}
+			default:
+				t.Fatalf("unexpected public key %T", public)
+			}
+		})
+	}
+}
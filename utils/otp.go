@@ -1,52 +1,40 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"sync"
-	"time"
-
-	"rondo/models"
-)
-
-// Global variables for OTP storage
-var (
-	OtpStore     = make(map[string]models.OTPData)
-	OtpStoreLock sync.RWMutex
+	"math/big"
 )
 
 // GenerateOTP generates a random 6-digit OTP
-func GenerateOTP() string {
-	buffer := make([]byte, 3)
-	rand.Read(buffer)
-	num := (int(buffer[0])*256*256 + int(buffer[1])*256 + int(buffer[2])) % 1000000
-	return fmt.Sprintf("%06d", num)
+func GenerateOTP() (string, error) {
+	num, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", num.Int64()), nil
 }
 
-// StoreOTP stores an OTP for a phone number
-func StoreOTP(phoneNumber, otp string) {
-	OtpStoreLock.Lock()
-	defer OtpStoreLock.Unlock()
-	
-	OtpStore[phoneNumber] = models.OTPData{
-		OTP:       otp,
-		CreatedAt: time.Now(),
+// NewOTPSalt returns a random salt for hashing an OTP
+func NewOTPSalt() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(buffer), nil
 }
 
-// GetOTP retrieves an OTP for a phone number
-func GetOTP(phoneNumber string) (models.OTPData, bool) {
-	OtpStoreLock.RLock()
-	defer OtpStoreLock.RUnlock()
-	
-	otpData, exists := OtpStore[phoneNumber]
-	return otpData, exists
+// HashOTP returns the salted hash stored in place of an OTP
+func HashOTP(otp, salt string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(otp))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// DeleteOTP removes an OTP from the store
-func DeleteOTP(phoneNumber string) {
-	OtpStoreLock.Lock()
-	defer OtpStoreLock.Unlock()
-	
-	delete(OtpStore, phoneNumber)
+// CheckOTP reports whether an OTP matches a stored hash, in constant time
+func CheckOTP(otp, salt, hash string) bool {
+	return hmac.Equal([]byte(HashOTP(otp, salt)), []byte(hash))
 }
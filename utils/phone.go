@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// ErrInvalidPhone is returned for input that is not a valid phone number
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// PhoneRegion is the ISO 3166 region assumed for phone numbers entered
+// without a country code
+var PhoneRegion = "US"
+
+// InitPhoneRegion sets the default region for phone numbers
+func InitPhoneRegion(region string) error {
+	region = strings.ToUpper(region)
+	if phonenumbers.GetCountryCodeForRegion(region) == 0 {
+		return fmt.Errorf("unknown phone region %q", region)
+	}
+	PhoneRegion = region
+	return nil
+}
+
+// NormalizePhone parses a phone number in any common notation, such as
+// "+1 555-0100" or "(555) 0100", and returns it in E.164 form. Numbers
+// without a country code are read as numbers of PhoneRegion.
+func NormalizePhone(input string) (string, error) {
+	number, err := phonenumbers.Parse(input, PhoneRegion)
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return "", ErrInvalidPhone
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRule is the subset of an RFC 5545 recurrence rule supported for game series:
+// FREQ=DAILY or WEEKLY with optional INTERVAL, BYDAY, COUNT and UNTIL
+type RRule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    time.Time
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRRule parses a recurrence rule such as "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=10".
+// A date-only UNTIL is inclusive of that whole day in loc.
+func ParseRRule(rule string, loc *time.Location) (RRule, error) {
+	r := RRule{Interval: 1}
+
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return RRule{}, fmt.Errorf("recurrence rule is empty")
+	}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found || value == "" {
+			return RRule{}, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+			if r.Freq != "DAILY" && r.Freq != "WEEKLY" {
+				return RRule{}, fmt.Errorf("unsupported FREQ %q, use DAILY or WEEKLY", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return RRule{}, fmt.Errorf("INTERVAL must be a positive integer")
+			}
+			r.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return RRule{}, fmt.Errorf("unsupported BYDAY value %q", day)
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return RRule{}, fmt.Errorf("COUNT must be a positive integer")
+			}
+			r.Count = count
+		case "UNTIL":
+			until, err := parseRRuleUntil(value, loc)
+			if err != nil {
+				return RRule{}, err
+			}
+			r.Until = until
+		default:
+			return RRule{}, fmt.Errorf("unsupported recurrence rule part %q", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return RRule{}, fmt.Errorf("recurrence rule must include FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return RRule{}, fmt.Errorf("COUNT and UNTIL cannot be used together")
+	}
+
+	return r, nil
+}
+
+// parseRRuleUntil parses a UTC date-time (20060102T150405Z) or a date (20060102)
+func parseRRuleUntil(value string, loc *time.Location) (time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return until, nil
+	}
+	if day, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL must be formatted as YYYYMMDD or YYYYMMDDThhmmssZ")
+}
+
+// Between returns the occurrences of the rule starting at dtstart that fall
+// in [after, before). COUNT is counted from dtstart, so earlier occurrences
+// still use up the count. Occurrences keep dtstart's wall-clock time in its
+// location across daylight saving changes.
+func (r RRule) Between(dtstart, after, before time.Time) []time.Time {
+	var occurrences []time.Time
+
+	loc := dtstart.Location()
+	hour, minute, second := dtstart.Clock()
+	year, month, day := dtstart.Date()
+	startDate := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	byDay := r.ByDay
+	if r.Freq == "WEEKLY" && len(byDay) == 0 {
+		byDay = []time.Weekday{dtstart.Weekday()}
+	}
+
+	count := 0
+	for date := startDate; ; date = date.AddDate(0, 0, 1) {
+		occurrence := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, dtstart.Nanosecond(), loc)
+		if !occurrence.Before(before) || (!r.Until.IsZero() && occurrence.After(r.Until)) {
+			break
+		}
+		if !r.matches(startDate, date, byDay) || occurrence.Before(dtstart) {
+			continue
+		}
+
+		count++
+		if r.Count > 0 && count > r.Count {
+			break
+		}
+		if !occurrence.Before(after) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	return occurrences
+}
+
+// matches reports whether a calendar date is part of the rule. Dates are
+// midnight UTC so day arithmetic is unaffected by daylight saving.
+func (r RRule) matches(startDate, date time.Time, byDay []time.Weekday) bool {
+	if len(byDay) > 0 && !containsWeekday(byDay, date.Weekday()) {
+		return false
+	}
+
+	days := int(date.Sub(startDate).Hours() / 24)
+	if r.Freq == "DAILY" {
+		return days%r.Interval == 0
+	}
+
+	// Weeks start on Monday (the RFC 5545 default WKST)
+	weeks := int(weekStart(date).Sub(weekStart(startDate)).Hours() / (24 * 7))
+	return weeks%r.Interval == 0
+}
+
+// weekStart returns the Monday on or before date
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
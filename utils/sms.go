@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"rondo/config"
+)
+
+// SMS providers selectable with SMS_PROVIDER
+const (
+	SMSProviderTwilio  = "twilio"
+	SMSProviderLog     = "log"
+	SMSProviderFile    = "file"
+	SMSProviderMemory  = "memory"
+	SMSProviderWebhook = "webhook"
+)
+
+// SMSSender delivers text messages to phone numbers
+type SMSSender interface {
+	// SendOTP sends a verification code
+	SendOTP(phoneNumber, otp string) error
+	// SendMessage sends an arbitrary text message
+	SendMessage(phoneNumber, body string) error
+}
+
+// SMSMessage is a text message recorded by the log, file and memory senders
+// and posted by the webhook sender. OTP is set for verification codes.
+type SMSMessage struct {
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	OTP    string    `json:"otp,omitempty"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// NewSMSSender returns the sender selected by the configuration
+func NewSMSSender(cfg config.Config) (SMSSender, error) {
+	switch cfg.SMSProvider {
+	case SMSProviderTwilio:
+		return NewTwilioClient(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFromNumber, cfg.TwilioAPIURL)
+	case SMSProviderLog:
+		return LogSMS{}, nil
+	case SMSProviderFile:
+		return NewFileSMS(cfg.SMSFilePath)
+	case SMSProviderMemory:
+		return NewMemorySMS(), nil
+	case SMSProviderWebhook:
+		return NewWebhookSMS(cfg.SMSWebhookURL, cfg.SMSWebhookToken)
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q", cfg.SMSProvider)
+	}
+}
+
+// otpMessage is the text of a verification code message
+func otpMessage(otp string) string {
+	return fmt.Sprintf("Hello user, the verification code is: %s", otp)
+}
+
+// LogSMS writes messages to the server log instead of sending them. It is
+// meant for local development.
+type LogSMS struct{}
+
+// SendOTP logs a verification code
+func (LogSMS) SendOTP(phoneNumber, otp string) error {
+	log.Printf("SMS to %s: %s", phoneNumber, otpMessage(otp))
+	return nil
+}
+
+// SendMessage logs a text message
+func (LogSMS) SendMessage(phoneNumber, body string) error {
+	log.Printf("SMS to %s: %s", phoneNumber, body)
+	return nil
+}
+
+// FileSMS appends messages to a file as JSON lines, so tests running the
+// server in another process can read the codes it sends
+type FileSMS struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSMS returns a sender writing to path, creating the file if needed
+func NewFileSMS(path string) (*FileSMS, error) {
+	if path == "" {
+		return nil, fmt.Errorf("SMS_FILE_PATH must be set for the file SMS provider")
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	return &FileSMS{path: path}, nil
+}
+
+// SendOTP records a verification code
+func (f *FileSMS) SendOTP(phoneNumber, otp string) error {
+	return f.write(SMSMessage{To: phoneNumber, Body: otpMessage(otp), OTP: otp, SentAt: time.Now()})
+}
+
+// SendMessage records a text message
+func (f *FileSMS) SendMessage(phoneNumber, body string) error {
+	return f.write(SMSMessage{To: phoneNumber, Body: body, SentAt: time.Now()})
+}
+
+func (f *FileSMS) write(message SMSMessage) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// MemorySMS keeps sent messages in memory for tests to inspect
+type MemorySMS struct {
+	mu       sync.Mutex
+	messages []SMSMessage
+}
+
+// NewMemorySMS returns an empty in-memory sender
+func NewMemorySMS() *MemorySMS {
+	return &MemorySMS{}
+}
+
+// SendOTP records a verification code
+func (m *MemorySMS) SendOTP(phoneNumber, otp string) error {
+	m.record(SMSMessage{To: phoneNumber, Body: otpMessage(otp), OTP: otp, SentAt: time.Now()})
+	return nil
+}
+
+// SendMessage records a text message
+func (m *MemorySMS) SendMessage(phoneNumber, body string) error {
+	m.record(SMSMessage{To: phoneNumber, Body: body, SentAt: time.Now()})
+	return nil
+}
+
+func (m *MemorySMS) record(message SMSMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+}
+
+// Messages returns the messages sent to a phone number, oldest first
+func (m *MemorySMS) Messages(phoneNumber string) []SMSMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []SMSMessage
+	for _, message := range m.messages {
+		if message.To == phoneNumber {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// LastOTP returns the most recent verification code sent to a phone number
+func (m *MemorySMS) LastOTP(phoneNumber string) (string, bool) {
+	messages := m.Messages(phoneNumber)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].OTP != "" {
+			return messages[i].OTP, true
+		}
+	}
+	return "", false
+}
+
+// WebhookSMS posts each message as JSON to a URL, for SMS providers without
+// a dedicated sender. The endpoint must answer with a 2xx status.
+type WebhookSMS struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookSMS returns a sender posting to url. A non-empty token is sent as
+// a bearer token.
+func NewWebhookSMS(url, token string) (*WebhookSMS, error) {
+	if url == "" {
+		return nil, fmt.Errorf("SMS_WEBHOOK_URL must be set for the webhook SMS provider")
+	}
+	return &WebhookSMS{url: url, token: token, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// SendOTP posts a verification code
+func (w *WebhookSMS) SendOTP(phoneNumber, otp string) error {
+	return w.post(SMSMessage{To: phoneNumber, Body: otpMessage(otp), OTP: otp, SentAt: time.Now()})
+}
+
+// SendMessage posts a text message
+func (w *WebhookSMS) SendMessage(phoneNumber, body string) error {
+	return w.post(SMSMessage{To: phoneNumber, Body: body, SentAt: time.Now()})
+}
+
+func (w *WebhookSMS) post(message SMSMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
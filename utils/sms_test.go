@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTwilioClientSendsToFakeServer(t *testing.T) {
+	var got struct {
+		path, user, password, to, from, body string
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.URL.Path
+		got.user, got.password, _ = r.BasicAuth()
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		got.to, got.from, got.body = r.PostForm.Get("To"), r.PostForm.Get("From"), r.PostForm.Get("Body")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "SM123", "status": "queued"}`))
+	}))
+	defer server.Close()
+
+	sender, err := NewTwilioClient("AC123", "secret", "+15550000000", server.URL)
+	if err != nil {
+		t.Fatalf("new twilio client: %v", err)
+	}
+	if err := sender.SendOTP("+15551234567", "123456"); err != nil {
+		t.Fatalf("send otp: %v", err)
+	}
+
+	if got.path != "/2010-04-01/Accounts/AC123/Messages.json" {
+		t.Errorf("path = %q", got.path)
+	}
+	if got.user != "AC123" || got.password != "secret" {
+		t.Errorf("basic auth = %q:%q", got.user, got.password)
+	}
+	if got.to != "+15551234567" || got.from != "+15550000000" {
+		t.Errorf("to = %q, from = %q", got.to, got.from)
+	}
+	if !strings.Contains(got.body, "123456") {
+		t.Errorf("body %q does not contain the code", got.body)
+	}
+}
+
+func TestTwilioClientReportsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number", "status": 400}`))
+	}))
+	defer server.Close()
+
+	sender, err := NewTwilioClient("AC123", "secret", "+15550000000", server.URL)
+	if err != nil {
+		t.Fatalf("new twilio client: %v", err)
+	}
+	if err := sender.SendMessage("not a number", "hello"); err == nil {
+		t.Error("send message: expected an error")
+	}
+}
+
+func TestWebhookSMSPostsMessages(t *testing.T) {
+	var message SMSMessage
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if message.To == "+15559999999" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	sender, err := NewWebhookSMS(server.URL, "token")
+	if err != nil {
+		t.Fatalf("new webhook sender: %v", err)
+	}
+	if err := sender.SendOTP("+15551234567", "654321"); err != nil {
+		t.Fatalf("send otp: %v", err)
+	}
+	if message.To != "+15551234567" || message.OTP != "654321" {
+		t.Errorf("message = %+v", message)
+	}
+	if authorization != "Bearer token" {
+		t.Errorf("authorization = %q", authorization)
+	}
+
+	if err := sender.SendMessage("+15559999999", "hello"); err == nil {
+		t.Error("send to failing endpoint: expected an error")
+	}
+}
+
+func TestMemorySMSRecordsCodes(t *testing.T) {
+	sender := NewMemorySMS()
+	sender.SendOTP("+15551234567", "111111")
+	sender.SendMessage("+15551234567", "Game cancelled")
+	sender.SendOTP("+15551234567", "222222")
+	sender.SendOTP("+15550000001", "333333")
+
+	if otp, ok := sender.LastOTP("+15551234567"); !ok || otp != "222222" {
+		t.Errorf("last otp = %q, %v", otp, ok)
+	}
+	if messages := sender.Messages("+15551234567"); len(messages) != 3 {
+		t.Errorf("messages = %d, want 3", len(messages))
+	}
+	if _, ok := sender.LastOTP("+15550000002"); ok {
+		t.Error("last otp for unknown number: expected none")
+	}
+}
+
+func TestFileSMSAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.jsonl")
+	sender, err := NewFileSMS(path)
+	if err != nil {
+		t.Fatalf("new file sender: %v", err)
+	}
+	sender.SendOTP("+15551234567", "123456")
+	sender.SendMessage("+15551234567", "Game cancelled")
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+
+	var messages []SMSMessage
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message SMSMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		messages = append(messages, message)
+	}
+
+	if len(messages) != 2 || messages[0].OTP != "123456" || messages[1].Body != "Game cancelled" {
+		t.Errorf("messages = %+v", messages)
+	}
+}
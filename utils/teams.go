@@ -0,0 +1,362 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ErrTeamConstraints is returned when the keep-together and keep-apart
+// constraints cannot all be met with the number of teams asked for
+var ErrTeamConstraints = errors.New("players cannot be split into teams with these constraints")
+
+// teamSplitAttempts is how many orders of the players BalanceTeams tries
+// before giving up on the keep-apart constraints
+const teamSplitAttempts = 20
+
+// TeamPlayer is a player to be placed on a team
+type TeamPlayer struct {
+	ID       string
+	Rating   float64
+	Position string // Preferred position, empty for no preference
+}
+
+// BalanceTeams splits players into count teams of as equal size as keep-together
+// groups allow. It evens out the teams' total ratings and spreads the players of
+// each position, such as goalkeepers, across the teams. Players in a keepTogether
+// group always share a team and players in a keepApart group are all on
+// different teams.
+//
+// rng picks between equally good splits, so the same players split with a
+// different source give another fair split. The result is deterministic for a
+// given source.
+func BalanceTeams(players []TeamPlayer, count int, keepTogether, keepApart [][]string, rng *rand.Rand) ([][]TeamPlayer, error) {
+	if count < 2 || count > len(players) {
+		return nil, fmt.Errorf("cannot split %d players into %d teams", len(players), count)
+	}
+
+	units, err := teamUnits(players, keepTogether, keepApart)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < teamSplitAttempts; attempt++ {
+		split := newTeamSplit(units, count, len(players))
+		if !split.place(rng) {
+			continue
+		}
+		split.improve()
+		return split.teams(), nil
+	}
+	return nil, ErrTeamConstraints
+}
+
+// teamUnit is a keep-together group, or a single player, placed as a whole
+type teamUnit struct {
+	players   []TeamPlayer
+	rating    float64
+	conflicts map[int]bool // Units that must be on another team
+}
+
+// teamUnits groups the players that must stay together and records which
+// groups must be kept apart
+func teamUnits(players []TeamPlayer, keepTogether, keepApart [][]string) ([]*teamUnit, error) {
+	index := make(map[string]int, len(players))
+	for i, player := range players {
+		if _, exists := index[player.ID]; exists {
+			return nil, fmt.Errorf("player %s is listed twice", player.ID)
+		}
+		index[player.ID] = i
+	}
+	lookup := func(id string) (int, error) {
+		i, exists := index[id]
+		if !exists {
+			return 0, fmt.Errorf("unknown player %s", id)
+		}
+		return i, nil
+	}
+
+	// Union-find over player indexes
+	parent := make([]int, len(players))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, group := range keepTogether {
+		first := -1
+		for _, id := range group {
+			i, err := lookup(id)
+			if err != nil {
+				return nil, err
+			}
+			if first < 0 {
+				first = i
+			}
+			parent[find(i)] = find(first)
+		}
+	}
+
+	// Units are numbered in the order their first player is listed
+	var units []*teamUnit
+	unitOf := make(map[int]int)
+	for i, player := range players {
+		root := find(i)
+		u, exists := unitOf[root]
+		if !exists {
+			u = len(units)
+			unitOf[root] = u
+			units = append(units, &teamUnit{conflicts: make(map[int]bool)})
+		}
+		units[u].players = append(units[u].players, player)
+		units[u].rating += player.Rating
+	}
+
+	for _, group := range keepApart {
+		for a := range group {
+			for b := a + 1; b < len(group); b++ {
+				i, err := lookup(group[a])
+				if err != nil {
+					return nil, err
+				}
+				j, err := lookup(group[b])
+				if err != nil {
+					return nil, err
+				}
+				u, v := unitOf[find(i)], unitOf[find(j)]
+				if u == v {
+					return nil, fmt.Errorf("%w: %s and %s must be both together and apart", ErrTeamConstraints, group[a], group[b])
+				}
+				units[u].conflicts[v] = true
+				units[v].conflicts[u] = true
+			}
+		}
+	}
+	return units, nil
+}
+
+// teamSplit is an assignment of units to teams
+type teamSplit struct {
+	units     []*teamUnit
+	members   [][]int // Unit indexes on each team
+	sizes     []int
+	sums      []float64
+	positions []map[string]int // Players of each position on each team
+	totals    map[string]int   // Players of each position overall
+	maxSize   int
+}
+
+func newTeamSplit(units []*teamUnit, count, players int) *teamSplit {
+	split := &teamSplit{
+		units:     units,
+		members:   make([][]int, count),
+		sizes:     make([]int, count),
+		sums:      make([]float64, count),
+		positions: make([]map[string]int, count),
+		totals:    make(map[string]int),
+		maxSize:   (players + count - 1) / count,
+	}
+	for t := range split.positions {
+		split.positions[t] = make(map[string]int)
+	}
+	for _, unit := range units {
+		for _, player := range unit.players {
+			if player.Position != "" {
+				split.totals[player.Position]++
+			}
+		}
+	}
+	return split
+}
+
+// place assigns every unit to a team in a random order, largest and most
+// constrained units first. It reports false if a unit conflicts with every team.
+func (s *teamSplit) place(rng *rand.Rand) bool {
+	order := rng.Perm(len(s.units))
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := s.units[order[i]], s.units[order[j]]
+		if len(a.players) != len(b.players) {
+			return len(a.players) > len(b.players)
+		}
+		return len(a.conflicts) > len(b.conflicts)
+	})
+
+	for _, u := range order {
+		// Teams are filled evenly: first by size, then by position spread,
+		// then by rating. Teams that are already full are only used when a
+		// keep-together group fits nowhere else.
+		best, bestFull := -1, true
+		var bestPenalty int
+		for t := range s.members {
+			if s.conflicts(u, t, -1) {
+				continue
+			}
+			full := s.sizes[t]+len(s.units[u].players) > s.maxSize
+			s.add(u, t)
+			penalty := s.positionPenalty()
+			s.remove(u, t)
+
+			better := best < 0
+			if !better && full != bestFull {
+				better = !full
+			} else if !better && s.sizes[t] != s.sizes[best] {
+				better = s.sizes[t] < s.sizes[best]
+			} else if !better && penalty != bestPenalty {
+				better = penalty < bestPenalty
+			} else if !better {
+				better = s.sums[t] < s.sums[best]
+			}
+			if better {
+				best, bestFull, bestPenalty = t, full, penalty
+			}
+		}
+		if best < 0 {
+			return false
+		}
+		s.add(u, best)
+	}
+	return true
+}
+
+// improve swaps units of the same size between teams while that makes the
+// split better, so team sizes never change
+func (s *teamSplit) improve() {
+	for improved := true; improved; {
+		improved = false
+		for a := range s.members {
+			for b := a + 1; b < len(s.members); b++ {
+				for i := 0; i < len(s.members[a]); i++ {
+					for j := 0; j < len(s.members[b]); j++ {
+						u, v := s.members[a][i], s.members[b][j]
+						if len(s.units[u].players) != len(s.units[v].players) ||
+							s.conflicts(u, b, v) || s.conflicts(v, a, u) {
+							continue
+						}
+
+						penalty, spread := s.positionPenalty(), s.spread()
+						s.swap(a, i, b, j)
+						if newPenalty := s.positionPenalty(); newPenalty < penalty ||
+							(newPenalty == penalty && s.spread() < spread-1e-9) {
+							improved = true
+							continue
+						}
+						s.swap(a, i, b, j)
+					}
+				}
+			}
+		}
+	}
+}
+
+// conflicts reports whether unit u must not join team t. The unit ignore
+// is left out, as it is about to leave the team.
+func (s *teamSplit) conflicts(u, t, ignore int) bool {
+	for _, v := range s.members[t] {
+		if v != ignore && s.units[u].conflicts[v] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *teamSplit) add(u, t int) {
+	s.members[t] = append(s.members[t], u)
+	s.sizes[t] += len(s.units[u].players)
+	s.sums[t] += s.units[u].rating
+	for _, player := range s.units[u].players {
+		if player.Position != "" {
+			s.positions[t][player.Position]++
+		}
+	}
+}
+
+// remove takes unit u off team t, where it must be the last unit added
+func (s *teamSplit) remove(u, t int) {
+	s.members[t] = s.members[t][:len(s.members[t])-1]
+	s.sizes[t] -= len(s.units[u].players)
+	s.sums[t] -= s.units[u].rating
+	for _, player := range s.units[u].players {
+		if player.Position != "" {
+			s.positions[t][player.Position]--
+		}
+	}
+}
+
+// swap exchanges the i-th unit of team a with the j-th unit of team b
+func (s *teamSplit) swap(a, i, b, j int) {
+	u, v := s.members[a][i], s.members[b][j]
+	s.members[a][i], s.members[b][j] = v, u
+	s.sums[a] += s.units[v].rating - s.units[u].rating
+	s.sums[b] += s.units[u].rating - s.units[v].rating
+	for _, player := range s.units[u].players {
+		if player.Position != "" {
+			s.positions[a][player.Position]--
+			s.positions[b][player.Position]++
+		}
+	}
+	for _, player := range s.units[v].players {
+		if player.Position != "" {
+			s.positions[b][player.Position]--
+			s.positions[a][player.Position]++
+		}
+	}
+}
+
+// positionPenalty counts how many players of each position a team has above
+// or below its even share
+func (s *teamSplit) positionPenalty() int {
+	teams := len(s.members)
+	penalty := 0
+	for position, total := range s.totals {
+		low, high := total/teams, (total+teams-1)/teams
+		for t := range s.members {
+			if n := s.positions[t][position]; n > high {
+				penalty += n - high
+			} else if n < low {
+				penalty += low - n
+			}
+		}
+	}
+	return penalty
+}
+
+// spread is the sum of squared differences between the teams' total
+// ratings and their mean
+func (s *teamSplit) spread() float64 {
+	var mean float64
+	for _, sum := range s.sums {
+		mean += sum
+	}
+	mean /= float64(len(s.sums))
+
+	var spread float64
+	for _, sum := range s.sums {
+		spread += (sum - mean) * (sum - mean)
+	}
+	return spread
+}
+
+// teams lists the players of each team, strongest first
+func (s *teamSplit) teams() [][]TeamPlayer {
+	teams := make([][]TeamPlayer, len(s.members))
+	for t, members := range s.members {
+		for _, u := range members {
+			teams[t] = append(teams[t], s.units[u].players...)
+		}
+		sort.SliceStable(teams[t], func(i, j int) bool {
+			a, b := teams[t][i], teams[t][j]
+			if math.Abs(a.Rating-b.Rating) > 1e-9 {
+				return a.Rating > b.Rating
+			}
+			return a.ID < b.ID
+		})
+	}
+	return teams
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// teamOf maps each player ID to the index of their team
+func teamOf(teams [][]TeamPlayer) map[string]int {
+	team := make(map[string]int)
+	for t, players := range teams {
+		for _, player := range players {
+			team[player.ID] = t
+		}
+	}
+	return team
+}
+
+func teamTotal(team []TeamPlayer) float64 {
+	var total float64
+	for _, player := range team {
+		total += player.Rating
+	}
+	return total
+}
+
+func TestBalanceTeamsEvensOutRatings(t *testing.T) {
+	var players []TeamPlayer
+	for i, rating := range []float64{1800, 1700, 1600, 1500, 1400, 1300, 1200, 1100} {
+		players = append(players, TeamPlayer{ID: fmt.Sprintf("p%d", i), Rating: rating})
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		teams, err := BalanceTeams(players, 2, nil, nil, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if len(teams[0]) != 4 || len(teams[1]) != 4 {
+			t.Fatalf("seed %d: team sizes %d and %d", seed, len(teams[0]), len(teams[1]))
+		}
+		// Swapping single players can always get within one rating step
+		if diff := math.Abs(teamTotal(teams[0]) - teamTotal(teams[1])); diff > 100 {
+			t.Errorf("seed %d: totals differ by %v", seed, diff)
+		}
+	}
+}
+
+func TestBalanceTeamsSpreadsPositions(t *testing.T) {
+	// The three goalkeepers are the strongest players, so balancing by
+	// rating alone would put two of them on the same team
+	players := []TeamPlayer{
+		{ID: "gk1", Rating: 1900, Position: "goalkeeper"},
+		{ID: "gk2", Rating: 1850, Position: "goalkeeper"},
+		{ID: "gk3", Rating: 1800, Position: "goalkeeper"},
+	}
+	for i := 0; i < 9; i++ {
+		players = append(players, TeamPlayer{ID: fmt.Sprintf("p%d", i), Rating: 1200 + float64(i)*10})
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		teams, err := BalanceTeams(players, 3, nil, nil, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		for i, team := range teams {
+			keepers := 0
+			for _, player := range team {
+				if player.Position == "goalkeeper" {
+					keepers++
+				}
+			}
+			if keepers != 1 || len(team) != 4 {
+				t.Errorf("seed %d: team %d has %d players and %d goalkeepers", seed, i, len(team), keepers)
+			}
+		}
+	}
+}
+
+func TestBalanceTeamsHonoursConstraints(t *testing.T) {
+	var players []TeamPlayer
+	for i := 0; i < 10; i++ {
+		players = append(players, TeamPlayer{ID: fmt.Sprintf("p%d", i), Rating: 1000 + float64(i)*100})
+	}
+	// The two strongest players want to play together, the two weakest too
+	together := [][]string{{"p9", "p8"}, {"p0", "p1"}}
+	apart := [][]string{{"p9", "p0"}, {"p5", "p6"}}
+
+	for seed := int64(0); seed < 20; seed++ {
+		teams, err := BalanceTeams(players, 2, together, apart, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		team := teamOf(teams)
+		if team["p9"] != team["p8"] || team["p0"] != team["p1"] {
+			t.Errorf("seed %d: keep-together players split: %v", seed, team)
+		}
+		if team["p9"] == team["p0"] || team["p5"] == team["p6"] {
+			t.Errorf("seed %d: keep-apart players together: %v", seed, team)
+		}
+		if len(teams[0]) != 5 || len(teams[1]) != 5 {
+			t.Errorf("seed %d: team sizes %d and %d", seed, len(teams[0]), len(teams[1]))
+		}
+	}
+}
+
+func TestBalanceTeamsRejectsImpossibleConstraints(t *testing.T) {
+	players := []TeamPlayer{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+	rng := rand.New(rand.NewSource(1))
+
+	// Three players who must all be apart cannot fit on two teams
+	if _, err := BalanceTeams(players, 2, nil, [][]string{{"a", "b", "c"}}, rng); !errors.Is(err, ErrTeamConstraints) {
+		t.Errorf("three apart on two teams: got %v", err)
+	}
+	if _, err := BalanceTeams(players, 2, [][]string{{"a", "b"}}, [][]string{{"b", "a"}}, rng); !errors.Is(err, ErrTeamConstraints) {
+		t.Errorf("together and apart: got %v", err)
+	}
+	if _, err := BalanceTeams(players, 2, [][]string{{"a", "x"}}, nil, rng); err == nil {
+		t.Error("unknown player: expected an error")
+	}
+	if _, err := BalanceTeams(players, 5, nil, nil, rng); err == nil {
+		t.Error("more teams than players: expected an error")
+	}
+	if _, err := BalanceTeams(players, 1, nil, nil, rng); err == nil {
+		t.Error("a single team: expected an error")
+	}
+}
+
+func TestBalanceTeamsIsDeterministicPerSource(t *testing.T) {
+	var players []TeamPlayer
+	for i := 0; i < 10; i++ {
+		players = append(players, TeamPlayer{ID: fmt.Sprintf("p%d", i), Rating: DefaultRating})
+	}
+
+	first, err := BalanceTeams(players, 2, nil, nil, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := BalanceTeams(players, 2, nil, nil, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("same source gave different splits:\n%v\n%v", first, again)
+	}
+
+	// Unrated players are all equal, so reshuffles should not keep
+	// producing the same teams
+	splits := make(map[string]bool)
+	for seed := int64(0); seed < 10; seed++ {
+		teams, err := BalanceTeams(players, 2, nil, nil, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		team := teamOf(teams)
+		// Name teams by whether they include p0 so that the order of the
+		// teams does not matter
+		key := ""
+		for i := 0; i < 10; i++ {
+			key += fmt.Sprint(team[fmt.Sprintf("p%d", i)] == team["p0"])
+		}
+		splits[key] = true
+	}
+	if len(splits) < 2 {
+		t.Error("ten reshuffles all gave the same split")
+	}
+}
@@ -2,63 +2,80 @@ package utils
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/twilio/twilio-go"
+	"github.com/twilio/twilio-go/client"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
-// TwilioClient handles Twilio API operations
+// TwilioClient sends text messages through the Twilio API
 type TwilioClient struct {
 	Client     *twilio.RestClient
-	AccountSID string
-	AuthToken  string
 	FromNumber string
 }
 
-// InitTwilio initializes and returns a new Twilio client
-func InitTwilio() *TwilioClient {
-	// Get credentials from environment variables
-	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
-	authToken := os.Getenv("TWILIO_AUTH_TOKEN")
-	fromNumber := os.Getenv("TWILIO_FROM_NUMBER")
-
-	// Check if credentials are available
-	if accountSid == "" || authToken == "" || fromNumber == "" {
-		log.Println("Warning: One or more Twilio credentials are missing from environment variables")
-		log.Println("Make sure TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN, and TWILIO_FROM_NUMBER are set in your .env file")
+// NewTwilioClient returns a Twilio sender. apiURL replaces the Twilio API
+// host, e.g. with a local fake server; leave it empty to use Twilio itself.
+func NewTwilioClient(accountSID, authToken, fromNumber, apiURL string) (*TwilioClient, error) {
+	if accountSID == "" || authToken == "" || fromNumber == "" {
+		return nil, fmt.Errorf("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER must be set")
 	}
 
-	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: accountSid,
-		Password: authToken,
-	})
+	params := twilio.ClientParams{Username: accountSID, Password: authToken}
+	if apiURL != "" {
+		target, err := url.Parse(apiURL)
+		if err != nil || target.Host == "" {
+			return nil, fmt.Errorf("invalid Twilio API URL %q", apiURL)
+		}
+		restClient := &client.Client{
+			Credentials: client.NewCredentials(accountSID, authToken),
+			HTTPClient: &http.Client{
+				Transport: rewriteHost{target: target},
+				Timeout:   10 * time.Second,
+			},
+		}
+		restClient.SetAccountSid(accountSID)
+		params.Client = restClient
+	}
 
 	return &TwilioClient{
-		Client:     client,
-		AccountSID: accountSid,
-		AuthToken:  authToken,
+		Client:     twilio.NewRestClientWithParams(params),
 		FromNumber: fromNumber,
-	}
+	}, nil
 }
 
 // SendOTP sends an OTP via Twilio SMS
 func (tc *TwilioClient) SendOTP(phoneNumber, otp string) error {
+	return tc.SendMessage(phoneNumber, otpMessage(otp))
+}
+
+// SendMessage sends an arbitrary text message via Twilio SMS
+func (tc *TwilioClient) SendMessage(phoneNumber, body string) error {
 	// Create the message params
 	params := &openapi.CreateMessageParams{}
 	params.SetTo(phoneNumber)
 	params.SetFrom(tc.FromNumber)
-	params.SetBody(fmt.Sprintf("Hello user, the verification code is: %s", otp))
+	params.SetBody(body)
 
 	// Send the message
-	_, err := tc.Client.Api.CreateMessage(params)
-	if err != nil {
-		fmt.Printf("Error sending SMS: %s\n", err.Error())
-		return err
+	if _, err := tc.Client.Api.CreateMessage(params); err != nil {
+		return fmt.Errorf("twilio: %w", err)
 	}
-
-	// For development, also print to console
-	fmt.Printf("Sending OTP %s to %s via Twilio\n", otp, phoneNumber)
 	return nil
 }
+
+// rewriteHost sends requests to another scheme and host, keeping their path
+type rewriteHost struct {
+	target *url.URL
+}
+
+func (r rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	req.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
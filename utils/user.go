@@ -2,7 +2,6 @@ package utils
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,14 +9,8 @@ import (
 	"rondo/models"
 )
 
-// UserStore is a simple in-memory storage for users
-var (
-	UserStore     = make(map[string]models.User) // Phone number -> User
-	UserStoreLock sync.RWMutex
-)
-
-// CreateUser creates a new user in the store
-func CreateUser(req models.UserRegistrationRequest, phoneNumber string) (models.User, error) {
+// NewUser builds a new user from a registration request
+func NewUser(req models.UserRegistrationRequest, phoneNumber string) (models.User, error) {
 	// Parse date of birth
 	dob, err := time.Parse("2006-01-02", req.DOB)
 	if err != nil {
@@ -35,30 +28,10 @@ func CreateUser(req models.UserRegistrationRequest, phoneNumber string) (models.
 		LastName:  req.LastName,
 		DOB:       dob,
 		Phone:     phoneNumber,
+		Role:      models.RolePlayer,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 	
-	// Store user
-	UserStoreLock.Lock()
-	defer UserStoreLock.Unlock()
-	
-	// Check if phone number already exists
-	for _, existingUser := range UserStore {
-		if existingUser.Phone == phoneNumber {
-			return models.User{}, fmt.Errorf("phone number already registered")
-		}
-	}
-	
-	UserStore[phoneNumber] = user
 	return user, nil
 }
-
-// GetUserByPhone retrieves a user by phone number
-func GetUserByPhone(phone string) (models.User, bool) {
-	UserStoreLock.RLock()
-	defer UserStoreLock.RUnlock()
-	
-	user, exists := UserStore[phone]
-	return user, exists
-}